@@ -4,24 +4,35 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/nycruz/gail/internal/config"
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/schema"
 	"github.com/spf13/viper"
 )
 
 type Assistant struct {
-	Logger *slog.Logger
-	Roles  []Role
-	Skills []Skill
+	Logger  *slog.Logger
+	Roles   []Role
+	Skills  []Skill
+	Presets []Preset
 }
 
 type AssistantConfig struct {
-	Roles  []Role  `mapstructure:"role"`
-	Skills []Skill `mapstructure:"skill"`
+	Roles   []Role   `mapstructure:"role"`
+	Skills  []Skill  `mapstructure:"skill"`
+	Presets []Preset `mapstructure:"preset"`
 }
 
 type Role struct {
 	ID      string `mapstructure:"id"`
 	Name    string `mapstructure:"name"`
 	Persona string `mapstructure:"persona"`
+	// The ID of the preset of generation parameters to use.
+	Preset string `mapstructure:"preset"`
+	// Generation parameters, overriding the ones of the preset.
+	models.Params `mapstructure:",squash"`
+	// The file the role comes from, e.g. "project: /src/app/.gail/assistants.toml".
+	Source string `mapstructure:"-"`
 }
 
 type Skill struct {
@@ -29,9 +40,36 @@ type Skill struct {
 	Instruction string   `mapstructure:"instruction"`
 	Description string   `mapstructure:"description"`
 	RoleIDs     []string `mapstructure:"roleIDs"`
+	// The ID of the preset of generation parameters to use.
+	Preset string `mapstructure:"preset"`
+	// Generation parameters, overriding the ones of the preset and the role.
+	models.Params `mapstructure:",squash"`
+	// A JSON Schema the answers must match. Skills with a schema get structured JSON answers.
+	Schema string `mapstructure:"schema"`
+	// The parsed Schema, nil when the skill has none.
+	JSONSchema *schema.Schema `mapstructure:"-"`
+	// The placeholders of the instruction, e.g. {{language}}, asked for when the skill is picked.
+	Variables []Variable `mapstructure:"variable"`
+	// The file the skill comes from, e.g. "user: ~/.config/gail/assistants.toml".
+	Source string `mapstructure:"-"`
 }
 
-// New creates a new Assistant instance.
+// Preset is a named set of generation parameters roles and skills can refer to.
+type Preset struct {
+	ID            string `mapstructure:"id"`
+	models.Params `mapstructure:",squash"`
+}
+
+const (
+	// SourceUser is the source of the entries of the global assistants file.
+	SourceUser = "user"
+	// SourceProject is the source of the entries of the project's assistants file.
+	SourceProject = "project"
+)
+
+// New creates a new Assistant instance from the global assistants file, merged with the
+// '.gail' one of the project closest to the working directory, if any. The roles, skills and
+// presets of the project replace the global ones of the same ID, and the others are added.
 func New(logger *slog.Logger, assistantsFilename string, configDirPath string) (*Assistant, error) {
 	fileExt := "toml"
 	viper.SetConfigName(assistantsFilename)
@@ -47,16 +85,157 @@ func New(logger *slog.Logger, assistantsFilename string, configDirPath string) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal the '%s.%s' config: %w", assistantsFilename, fileExt, err)
 	}
+	ac.setSource(fmt.Sprintf("%s: %s", SourceUser, viper.ConfigFileUsed()))
+
+	projectPath, err := config.FindProjectFile(assistantsFilename + "." + fileExt)
+	if err != nil {
+		return nil, err
+	}
+	if projectPath != "" {
+		project, err := readProjectFile(projectPath)
+		if err != nil {
+			return nil, err
+		}
+		ac.merge(project)
+
+		logger.Info(
+			"Merged the project's assistants.",
+			slog.String("path", projectPath),
+			slog.Int("roles_count", len(project.Roles)),
+			slog.Int("skills_count", len(project.Skills)),
+		)
+	}
 
 	a := &Assistant{
-		Logger: logger,
-		Roles:  ac.Roles,
-		Skills: ac.Skills,
+		Logger:  logger,
+		Roles:   ac.Roles,
+		Skills:  ac.Skills,
+		Presets: ac.Presets,
+	}
+
+	if err := a.validatePresets(); err != nil {
+		return nil, fmt.Errorf("invalid '%s.%s' config: %w", assistantsFilename, fileExt, err)
+	}
+
+	if err := a.parseSchemas(); err != nil {
+		return nil, fmt.Errorf("invalid '%s.%s' config: %w", assistantsFilename, fileExt, err)
+	}
+
+	if err := a.validateVariables(); err != nil {
+		return nil, fmt.Errorf("invalid '%s.%s' config: %w", assistantsFilename, fileExt, err)
 	}
 
 	return a, nil
 }
 
+// readProjectFile reads the assistants file of a project.
+func readProjectFile(path string) (*AssistantConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read the '%s' config file: %w", path, err)
+	}
+
+	var ac AssistantConfig
+	if err := v.Unmarshal(&ac); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the '%s' config: %w", path, err)
+	}
+	ac.setSource(fmt.Sprintf("%s: %s", SourceProject, path))
+
+	return &ac, nil
+}
+
+// setSource sets the source of the roles and skills of the config.
+func (ac *AssistantConfig) setSource(source string) {
+	for i := range ac.Roles {
+		ac.Roles[i].Source = source
+	}
+	for i := range ac.Skills {
+		ac.Skills[i].Source = source
+	}
+}
+
+// merge adds the roles, skills and presets of the other config, replacing the ones of the same ID in place.
+func (ac *AssistantConfig) merge(other *AssistantConfig) {
+	ac.Roles = mergeByID(ac.Roles, other.Roles, func(r Role) string { return r.ID })
+	ac.Skills = mergeByID(ac.Skills, other.Skills, func(s Skill) string { return s.ID })
+	ac.Presets = mergeByID(ac.Presets, other.Presets, func(p Preset) string { return p.ID })
+}
+
+// mergeByID adds the overrides to the items, replacing the item of the same ID in place.
+func mergeByID[T any](items []T, overrides []T, id func(T) string) []T {
+	for _, override := range overrides {
+		replaced := false
+		for i, item := range items {
+			if id(item) == id(override) {
+				items[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			items = append(items, override)
+		}
+	}
+	return items
+}
+
+// validatePresets checks that the presets referred to by roles and skills exist.
+func (a *Assistant) validatePresets() error {
+	for _, role := range a.Roles {
+		if _, ok := a.findPreset(role.Preset); !ok {
+			return fmt.Errorf("role '%s' refers to the unknown preset '%s'", role.ID, role.Preset)
+		}
+	}
+	for _, skill := range a.Skills {
+		if _, ok := a.findPreset(skill.Preset); !ok {
+			return fmt.Errorf("skill '%s' refers to the unknown preset '%s'", skill.ID, skill.Preset)
+		}
+	}
+	return nil
+}
+
+// parseSchemas parses the JSON Schema of the skills that declare one.
+func (a *Assistant) parseSchemas() error {
+	for i, skill := range a.Skills {
+		if skill.Schema == "" {
+			continue
+		}
+		s, err := schema.Parse(skill.ID, skill.Schema)
+		if err != nil {
+			return fmt.Errorf("skill '%s': %w", skill.ID, err)
+		}
+		a.Skills[i].JSONSchema = s
+	}
+	return nil
+}
+
+// findPreset returns the preset with the given ID. An empty ID refers to no preset.
+func (a *Assistant) findPreset(id string) (Preset, bool) {
+	if id == "" {
+		return Preset{}, true
+	}
+	for _, preset := range a.Presets {
+		if preset.ID == id {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// Params returns the generation parameters for a role and a skill.
+// From lowest to highest precedence: the role's preset, the role, the skill's preset and the skill.
+func (a *Assistant) Params(role Role, skill Skill) models.Params {
+	rolePreset, _ := a.findPreset(role.Preset)
+	skillPreset, _ := a.findPreset(skill.Preset)
+
+	return rolePreset.Params.
+		Merge(role.Params).
+		Merge(skillPreset.Params).
+		Merge(skill.Params)
+}
+
 // DefaultRole returns the default role.
 func (a *Assistant) DefaultRole() Role {
 	for _, role := range a.Roles {
@@ -87,6 +266,16 @@ func (a *Assistant) FindRoleByID(id string) Role {
 	return Role{}
 }
 
+// FindSkillByID returns a skill by its ID.
+func (a *Assistant) FindSkillByID(id string) Skill {
+	for _, skill := range a.Skills {
+		if skill.ID == id {
+			return skill
+		}
+	}
+	return Skill{}
+}
+
 // GetRoleSkills returns a list of skills for a given role ID.
 func (a *Assistant) GetRoleSkills(roleID string) []Skill {
 	var skills []Skill
@@ -0,0 +1,149 @@
+package assistant
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Variable is a placeholder of a skill's instruction, such as {{language}}, filled when the skill is picked.
+type Variable struct {
+	Name string `mapstructure:"name"`
+	// The value the form starts with. Defaults to the built-in value of the same name, if any.
+	Default string `mapstructure:"default"`
+	// The values to pick from. Any value can be typed when empty.
+	Choices []string `mapstructure:"choices"`
+}
+
+const (
+	// BuiltinCwd is the built-in variable holding the working directory.
+	BuiltinCwd = "cwd"
+	// BuiltinGitBranch is the built-in variable holding the current git branch, empty outside a repository.
+	BuiltinGitBranch = "git_branch"
+	// BuiltinOS is the built-in variable holding the operating system, e.g. "linux".
+	BuiltinOS = "os"
+)
+
+var (
+	// placeholderRe matches the placeholders of an instruction, e.g. {{language}} or {{ git_branch }}.
+	placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	// variableNameRe matches the names the variables can have.
+	variableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// isBuiltin reports whether name is a built-in variable.
+func isBuiltin(name string) bool {
+	return name == BuiltinCwd || name == BuiltinGitBranch || name == BuiltinOS
+}
+
+// BuiltinValues returns the values of the built-in variables.
+func BuiltinValues() map[string]string {
+	values := map[string]string{
+		BuiltinOS: runtime.GOOS,
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		values[BuiltinCwd] = cwd
+	}
+	if out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+		values[BuiltinGitBranch] = strings.TrimSpace(string(out))
+	}
+	return values
+}
+
+// placeholders returns the names of the placeholders of the instruction, in order of first appearance.
+func placeholders(instruction string) []string {
+	var names []string
+	for _, match := range placeholderRe.FindAllStringSubmatch(instruction, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// IsTemplate reports whether the skill's instruction has placeholders to fill.
+func (s Skill) IsTemplate() bool {
+	return placeholderRe.MatchString(s.Instruction)
+}
+
+// Prompted returns the variables to ask for when the skill is picked, in order of appearance in
+// the instruction: the declared ones, and the other placeholders that are not built-in.
+// The variables without a default get the built-in value of the same name, if it is one of their choices.
+func (s Skill) Prompted(builtins map[string]string) []Variable {
+	var variables []Variable
+	for _, name := range placeholders(s.Instruction) {
+		i := slices.IndexFunc(s.Variables, func(v Variable) bool { return v.Name == name })
+		switch {
+		case i >= 0:
+			variable := s.Variables[i]
+			if value, ok := builtins[name]; ok && variable.Default == "" && (len(variable.Choices) == 0 || slices.Contains(variable.Choices, value)) {
+				variable.Default = value
+			}
+			variables = append(variables, variable)
+		case !isBuiltin(name):
+			variables = append(variables, Variable{Name: name})
+		}
+	}
+	return variables
+}
+
+// Render returns the skill with the placeholders of its instruction replaced by the values,
+// or else by the built-in values. The placeholders without a value are left as they are.
+func (s Skill) Render(values map[string]string, builtins map[string]string) Skill {
+	s.Instruction = placeholderRe.ReplaceAllStringFunc(s.Instruction, func(placeholder string) string {
+		name := placeholderRe.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		if value, ok := builtins[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+	return s
+}
+
+// Defaults returns the default values of the variables, the first choice standing for the
+// choice variables without a default.
+func Defaults(variables []Variable) map[string]string {
+	values := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		value := variable.Default
+		if value == "" && len(variable.Choices) > 0 {
+			value = variable.Choices[0]
+		}
+		values[variable.Name] = value
+	}
+	return values
+}
+
+// validateVariables checks the variables declared by the skills, which must all be placeholders of their instruction.
+func (a *Assistant) validateVariables() error {
+	for _, skill := range a.Skills {
+		used := placeholders(skill.Instruction)
+		var names []string
+		for i, variable := range skill.Variables {
+			if variable.Name == "" {
+				return fmt.Errorf("skill '%s': variable %d has no name", skill.ID, i+1)
+			}
+			if !variableNameRe.MatchString(variable.Name) {
+				return fmt.Errorf("skill '%s': variable '%s' must be made of letters, digits and underscores", skill.ID, variable.Name)
+			}
+			if slices.Contains(names, variable.Name) {
+				return fmt.Errorf("skill '%s': variable '%s' is declared twice", skill.ID, variable.Name)
+			}
+			if !slices.Contains(used, variable.Name) {
+				return fmt.Errorf("skill '%s': variable '%s' is not a placeholder of the instruction", skill.ID, variable.Name)
+			}
+			if variable.Default != "" && len(variable.Choices) > 0 && !slices.Contains(variable.Choices, variable.Default) {
+				return fmt.Errorf("skill '%s': the default '%s' of variable '%s' is not one of its choices", skill.ID, variable.Default, variable.Name)
+			}
+			names = append(names, variable.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/validator"
+)
+
+// FileName is the default name of the audit log file in the configuration directory.
+const FileName = "audit.jsonl"
+
+// headSuffix is appended to the path of the audit log to name the file holding its head.
+const headSuffix = ".head"
+
+// Action is what was done with a prompt.
+type Action string
+
+const (
+	// ActionSent is a prompt sent as typed.
+	ActionSent Action = "sent"
+	// ActionConfirmed is a prompt sent after the user confirmed the warnings.
+	ActionConfirmed Action = "confirmed"
+	// ActionRedacted is a prompt sent with placeholders instead of some values.
+	ActionRedacted Action = "redacted"
+	// ActionBlocked is a prompt refused by the validation rules, that was not sent.
+	ActionBlocked Action = "blocked"
+	// ActionHandover is a conversation sent to a model taking it over from another one.
+	ActionHandover Action = "handover"
+	// ActionContinue is a request asking a model to carry on with its incomplete answer.
+	ActionContinue Action = "continue"
+	// ActionFailed is a request recorded before it was sent, that failed.
+	ActionFailed Action = "failed"
+)
+
+// Entry is a line of the audit log, recording a request sent to a model.
+// The prompt itself is not recorded, only its hash and size.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Role     string    `json:"role,omitempty"`
+	Skill    string    `json:"skill,omitempty"`
+	// The SHA-256 hash of the prompt, hex encoded.
+	PromptHash string `json:"promptHash"`
+	// The size of the prompt in bytes.
+	PromptSize int       `json:"promptSize"`
+	Findings   []Finding `json:"findings,omitempty"`
+	Action     Action    `json:"action"`
+	// The hash of the previous entry, empty for the first one.
+	Prev string `json:"prev"`
+	// The hash of the entry, chaining it to the previous one.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Finding is a validation finding of the prompt, without the matched value.
+type Finding struct {
+	Name     string             `json:"name"`
+	Action   validator.Action   `json:"action"`
+	Severity validator.Severity `json:"severity"`
+	Start    int                `json:"start"`
+	End      int                `json:"end"`
+}
+
+// NewEntry builds the entry recording the request sent to the given model.
+// The prompt is the text sent, once redacted, or refused when blocked.
+func NewEntry(provider string, model string, request models.Request, prompt string, findings []validator.Finding, action Action) Entry {
+	sum := sha256.Sum256([]byte(prompt))
+
+	entry := Entry{
+		Time:       time.Now().UTC(),
+		Provider:   provider,
+		Model:      model,
+		Role:       request.RoleName,
+		Skill:      request.SkillID,
+		PromptHash: hex.EncodeToString(sum[:]),
+		PromptSize: len(prompt),
+		Action:     action,
+	}
+	for _, f := range findings {
+		entry.Findings = append(entry.Findings, Finding{
+			Name:     f.Name,
+			Action:   f.Action,
+			Severity: f.Severity,
+			Start:    f.Start,
+			End:      f.End,
+		})
+	}
+
+	return entry
+}
+
+// SentAction returns the action taken on a prompt that was sent with the given findings and substitutions.
+func SentAction(findings []validator.Finding, substitutions []models.Substitution) Action {
+	if len(substitutions) > 0 {
+		return ActionRedacted
+	}
+	if len(validator.Filter(findings, validator.ActionWarn)) > 0 {
+		return ActionConfirmed
+	}
+	return ActionSent
+}
+
+// Log is an append-only audit log whose entries are chained by their hashes,
+// so that editing or removing an entry breaks the chain. The hash of the last entry
+// and the number of entries are kept in a head file next to the log, so that
+// removing the last entries is detected too.
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	lastHash string
+	count    int
+}
+
+// head is the content of the head file of an audit log.
+type head struct {
+	// The hash of the last entry.
+	Hash string `json:"hash"`
+	// The number of entries.
+	Count int `json:"count"`
+}
+
+// Open opens the audit log at path, creating it when it does not exist.
+func Open(path string) (*Log, error) {
+	lastHash, count, err := verify(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open the audit log '%s': %w", path, err)
+	}
+
+	return &Log{path: path, lastHash: lastHash, count: count}, nil
+}
+
+// Record appends the entry to the audit log. Recording to a nil Log does nothing,
+// so that the pipeline can record whether the audit log is enabled or not.
+func (l *Log) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Prev = l.lastHash
+	hash, err := entryHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("unable to json marshal the audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log '%s': %w", l.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write to the audit log '%s': %w", l.path, err)
+	}
+	l.lastHash = hash
+	l.count++
+
+	return l.writeHead()
+}
+
+// writeHead replaces the head file of the audit log with its last hash and number of entries.
+// The caller must hold the lock, or be the only one having the Log.
+func (l *Log) writeHead() error {
+	b, err := json.Marshal(head{Hash: l.lastHash, Count: l.count})
+	if err != nil {
+		return fmt.Errorf("unable to json marshal the audit log head: %w", err)
+	}
+
+	// The head is written aside and renamed, so that it is never left half written.
+	path := l.path + headSuffix
+	if err := os.WriteFile(path+".tmp", append(b, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write the audit log head '%s': %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write the audit log head '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// readHead reads the head file of the audit log at path.
+func readHead(path string) (head, error) {
+	b, err := os.ReadFile(path + headSuffix)
+	if err != nil {
+		return head{}, err
+	}
+
+	var h head
+	if err := json.Unmarshal(b, &h); err != nil {
+		return head{}, fmt.Errorf("invalid head file '%s': %w", path+headSuffix, err)
+	}
+	return h, nil
+}
+
+// check reports an error when the head does not match the last hash and number of entries of the log.
+func (h head) check(lastHash string, count int) error {
+	if count < h.Count {
+		return fmt.Errorf("the log has %d entries, %d were recorded: the last entries were removed", count, h.Count)
+	}
+	if count != h.Count || lastHash != h.Hash {
+		return fmt.Errorf("the last entry does not match the head file, it was changed or the log was replaced")
+	}
+	return nil
+}
+
+// Verify checks the hash chain of the audit log at path and that its last entry matches
+// its head file, and returns the number of entries verified.
+func Verify(path string) (int, error) {
+	_, count, err := verify(path)
+	return count, err
+}
+
+// verify checks the audit log at path, and returns the hash of its last entry and its number of entries.
+func verify(path string) (string, int, error) {
+	lastHash, count, err := readChain(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if h, headErr := readHead(path); headErr == nil && h.Count > 0 {
+			return "", 0, fmt.Errorf("the log is missing, %d entries were recorded", h.Count)
+		}
+	}
+	if err != nil {
+		return "", count, err
+	}
+
+	h, err := readHead(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if count == 0 {
+			return "", 0, nil
+		}
+		return "", count, fmt.Errorf("the head file '%s' is missing", path+headSuffix)
+	}
+	if err != nil {
+		return "", count, err
+	}
+	if err := h.check(lastHash, count); err != nil {
+		return "", count, err
+	}
+
+	return lastHash, count, nil
+}
+
+// readChain reads the audit log at path, verifying its hash chain,
+// and returns the hash of its last entry and its number of entries.
+func readChain(path string) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	lastHash := ""
+	count := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+			var entry Entry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return "", count, fmt.Errorf("entry %d: invalid entry: %w", count, err)
+			}
+			if entry.Prev != lastHash {
+				return "", count, fmt.Errorf("entry %d: the chain is broken, the previous entry was changed or removed", count)
+			}
+
+			hash := entry.Hash
+			entry.Hash = ""
+			expected, hashErr := entryHash(entry)
+			if hashErr != nil {
+				return "", count, hashErr
+			}
+			if hash != expected {
+				return "", count, fmt.Errorf("entry %d: the entry was changed, its hash does not match its content", count)
+			}
+			lastHash = hash
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", count, err
+		}
+	}
+
+	return lastHash, count, nil
+}
+
+// entryHash returns the hash of the entry, whose Hash field must be empty.
+func entryHash(entry Entry) (string, error) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("unable to json marshal the audit entry: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
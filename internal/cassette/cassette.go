@@ -0,0 +1,295 @@
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// FileName is the name of the cassette file in the record/replay directory.
+const FileName = "cassette.json"
+
+// redacted replaces the values that must not be written to a cassette.
+const redacted = "REDACTED"
+
+// sensitiveHeaders are request and response headers holding credentials or account details.
+var sensitiveHeaders = []string{"Authorization", "X-Api-Key", "Cookie", "Set-Cookie", "Openai-Organization", "Openai-Project"}
+
+// sensitiveFields are the fields of the response bodies echoing the instructions back,
+// such as the instructions and the description, holding the persona, of an OpenAI Assistant.
+var sensitiveFields = []string{"instructions", "description", "system"}
+
+// Cassette holds recorded HTTP interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and the response it got.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request. Credentials are redacted and the
+// body, which holds the user's prompt, is only kept as a SHA-256 digest.
+type Request struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header"`
+	BodySHA256 string      `json:"body_sha256,omitempty"`
+}
+
+// Response is a recorded HTTP response. The parts of the body echoing the prompt
+// and the instructions back are redacted.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is a http.RoundTripper sending requests through the given transport
+// and recording them, along with their responses, to a cassette file.
+type Recorder struct {
+	transport http.RoundTripper
+	path      string
+	mu        sync.Mutex
+	cassette  Cassette
+}
+
+// NewRecorder creates a Recorder writing to the cassette file of the given directory.
+func NewRecorder(dir string, transport http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the cassette directory '%s': %w", dir, err)
+	}
+
+	r := &Recorder{
+		transport: transport,
+		path:      filepath.Join(dir, FileName),
+	}
+
+	return r, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recordedRequest, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: unable to read the http response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recordedRequest,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       string(redactBody(body)),
+		},
+	})
+
+	// The whole cassette is written after every interaction so nothing is lost when Gail exits.
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) save() error {
+	content, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: unable to json marshal the cassette: %w", err)
+	}
+
+	if err := os.WriteFile(r.path, content, 0644); err != nil {
+		return fmt.Errorf("cassette: failed to write '%s': %w", r.path, err)
+	}
+
+	return nil
+}
+
+// Replayer is a http.RoundTripper answering requests from a cassette file, without any network access.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewReplayer creates a Replayer reading the cassette file of the given directory.
+func NewReplayer(dir string) (*Replayer, error) {
+	path := filepath.Join(dir, FileName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cassette '%s': %w", path, err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("failed to json decode the cassette '%s': %w", path, err)
+	}
+
+	r := &Replayer{
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}
+
+	return r, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+// Each request is answered with the first interaction not replayed yet having the same method and URL,
+// so repeated requests (e.g. polling) are answered in the recorded order.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != req.URL.String() {
+			continue
+		}
+		r.used[i] = true
+
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("cassette: no recorded interaction left for %s %s", req.Method, req.URL)
+}
+
+// newRequest records the request, redacting its credentials and replacing its body with a digest.
+// The request body is restored so it can still be sent.
+func newRequest(req *http.Request) (Request, error) {
+	recordedRequest := Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: redactHeader(req.Header),
+	}
+
+	if req.Body == nil {
+		return recordedRequest, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return Request{}, fmt.Errorf("cassette: unable to read the http request body: %w", err)
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	digest := sha256.Sum256(body)
+	recordedRequest.BodySHA256 = hex.EncodeToString(digest[:])
+
+	return recordedRequest, nil
+}
+
+// redactBody returns the JSON response body without the instructions and prompts it echoes back:
+// the sensitive fields, and the content of the user messages (e.g. the messages of an OpenAI Thread).
+// Other bodies are returned as they are.
+func redactBody(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+
+	redactedBody, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return body
+	}
+	return redactedBody
+}
+
+// redactValue redacts the sensitive fields and the content of the user messages of a JSON value.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			switch {
+			case slices.Contains(sensitiveFields, key):
+				if _, ok := field.(string); ok {
+					v[key] = redacted
+				}
+			case key == "content" && v["role"] == "user":
+				v[key] = redactStrings(field)
+			default:
+				v[key] = redactValue(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// redactStrings replaces all the strings of a JSON value, but the types of the content parts.
+func redactStrings(value any) any {
+	switch v := value.(type) {
+	case string:
+		return redacted
+	case map[string]any:
+		for key, field := range v {
+			if key != "type" {
+				v[key] = redactStrings(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactStrings(v[i])
+		}
+	}
+	return value
+}
+
+// redactHeader returns a copy of the header without its credentials.
+func redactHeader(h http.Header) http.Header {
+	header := h.Clone()
+	for _, name := range sensitiveHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	return header
+}
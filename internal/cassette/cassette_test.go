@@ -0,0 +1,150 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc is a http.RoundTripper answering with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+const echoingBody = `{
+	"object": "list",
+	"instructions": "You are a Software Engineer. Review the secret project.",
+	"data": [
+		{"role": "assistant", "content": [{"type": "text", "text": {"value": "The answer.", "annotations": []}}]},
+		{"role": "user", "content": [{"type": "text", "text": {"value": "My secret prompt", "annotations": []}}]}
+	],
+	"usage": {"prompt_tokens": 12}
+}`
+
+func TestRecorderRedactsCredentialsAndPrompts(t *testing.T) {
+	dir := t.TempDir()
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != `{"content":"My secret prompt"}` {
+			t.Errorf("the request body sent is %q, want the original body", body)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Set-Cookie": []string{"session=abc"}, "X-Request-Id": []string{"req_1"}},
+			Body:       io.NopCloser(strings.NewReader(echoingBody)),
+		}, nil
+	})
+
+	recorder, err := NewRecorder(dir, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.openai.com/v1/threads/thread_1/messages", strings.NewReader(`{"content":"My secret prompt"}`))
+	req.Header.Set("Authorization", "Bearer sk-secret")
+	resp, err := recorder.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != echoingBody {
+		t.Errorf("the response body given to the client was changed: %s", body)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cassette := string(content)
+	for _, secret := range []string{"sk-secret", "session=abc", "My secret prompt", "secret project"} {
+		if strings.Contains(cassette, secret) {
+			t.Errorf("the cassette contains %q:\n%s", secret, cassette)
+		}
+	}
+	for _, kept := range []string{"The answer.", "req_1", "body_sha256", `\"prompt_tokens\":12`} {
+		if !strings.Contains(cassette, kept) {
+			t.Errorf("the cassette does not contain %q:\n%s", kept, cassette)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "not json",
+			body: "upstream connect error",
+			want: "upstream connect error",
+		},
+		{
+			name: "assistant instructions and persona",
+			body: `{"id":"asst_1","description":"Gail: You are a SRE.","instructions":"Be concise."}`,
+			want: `{"description":"REDACTED","id":"asst_1","instructions":"REDACTED"}`,
+		},
+		{
+			name: "user message with string content",
+			body: `{"messages":[{"role":"user","content":"hello"},{"role":"assistant","content":"hi"}]}`,
+			want: `{"messages":[{"content":"REDACTED","role":"user"},{"content":"hi","role":"assistant"}]}`,
+		},
+		{
+			name: "tool input kept",
+			body: `{"content":[{"type":"tool_use","name":"answer","input":{"findings":[]}}],"stop_reason":"tool_use"}`,
+			want: `{"content":[{"input":{"findings":[]},"name":"answer","type":"tool_use"}],"stop_reason":"tool_use"}`,
+		},
+		{
+			name: "large numbers kept",
+			body: `{"created_at":1712345678901234567}`,
+			want: `{"created_at":1712345678901234567}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactBody([]byte(tt.body))); got != tt.want {
+				t.Errorf("redactBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayerAnswersInRecordedOrder(t *testing.T) {
+	dir := t.TempDir()
+	cassette := `{"interactions": [
+		{"request": {"method": "GET", "url": "https://api.openai.com/v1/threads/t/runs/r"}, "response": {"status_code": 200, "body": "{\"status\":\"queued\"}"}},
+		{"request": {"method": "POST", "url": "https://api.openai.com/v1/threads"}, "response": {"status_code": 200, "body": "{\"id\":\"t\"}"}},
+		{"request": {"method": "GET", "url": "https://api.openai.com/v1/threads/t/runs/r"}, "response": {"status_code": 200, "body": "{\"status\":\"completed\"}"}}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`{"status":"queued"}`, `{"status":"completed"}`} {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.openai.com/v1/threads/t/runs/r", nil)
+		resp, err := replayer.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != want {
+			t.Errorf("got %s, want %s", body, want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.openai.com/v1/threads/t/runs/r", nil)
+	if _, err := replayer.RoundTrip(req); err == nil {
+		t.Error("expected an error once the recorded interactions are used up")
+	}
+}
@@ -1,12 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/nycruz/gail/internal/models"
+	"github.com/spf13/viper"
 )
 
 // Config holds configuration data needed by the application.
@@ -15,27 +17,67 @@ type Config struct {
 	ModelMaxToken models.Token
 	ModelAPIKey   string
 	ConfigDir     string
+	// Ordered list of model flags (e.g. claude, gpt) to fall back to when the model fails.
+	Fallback []string
+	Audit    AuditConfig
+	// How many times in a row an answer cut off by the max tokens limit is continued without asking, 0 to always ask.
+	AutoContinue int
 }
 
+// SettingsConfig holds the settings read from the 'config.toml' file.
+type SettingsConfig struct {
+	Fallback     []string    `mapstructure:"fallback"`
+	Audit        AuditConfig `mapstructure:"audit"`
+	AutoContinue int         `mapstructure:"auto_continue"`
+}
+
+// AuditConfig holds the settings of the audit log of the requests sent to the models.
+type AuditConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// The path of the audit log file, in the configuration directory when empty.
+	Path string `mapstructure:"path"`
+}
+
+// ModelConfig holds the configuration needed to instantiate a LLM model.
+type ModelConfig struct {
+	Model     models.Model
+	MaxTokens models.Token
+	APIKey    string
+}
+
+// SettingsFileName is the name of the settings file in the configuration directory.
+const SettingsFileName = "config"
+
+// ProjectDirName is the directory holding the settings of a project, such as its own validation rules.
+const ProjectDirName = ".gail"
+
+// maxAutoContinue caps the auto_continue setting, so that a model never reaching the end
+// of its answer cannot burn through the tokens unattended.
+const maxAutoContinue = 10
+
+// EnvOpenAIAPIKey is the environment variable holding the OpenAI API key.
+const EnvOpenAIAPIKey = "OPENAI_API_KEY"
+
 const (
-	envOpenAIAPIKey = "OPENAI_API_KEY"
 	envClaudeAPIKey = "CLAUDE_API_KEY"
 	configDirName   = ".config/gail"
 	configFileExt   = "toml"
 )
 
 // New initializes a new Config struct based on the provided model flag and configures the necessary files.
-func New(modelFlag, validationsFilename, assistantsFilename string) (*Config, error) {
-	configDirPath, err := createConfigDir(configDirName)
+// The model's API key is not required when requireAPIKey is false (e.g. when replaying recorded answers).
+func New(modelFlag string, requireAPIKey bool, validationsFilename, assistantsFilename string) (*Config, error) {
+	configDirPath, err := Dir(validationsFilename, assistantsFilename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create the '%s' directory: %w", configDirName, err)
+		return nil, err
 	}
 
-	if err := createConfigFiles(configDirPath, validationsFilename, assistantsFilename); err != nil {
+	settings, err := ReadSettings(configDirPath)
+	if err != nil {
 		return nil, err
 	}
 
-	modelName, maxTokens, apiKey, err := selectModelConfig(modelFlag)
+	modelName, maxTokens, apiKey, err := selectModelConfig(modelFlag, requireAPIKey)
 	if err != nil {
 		return nil, err
 	}
@@ -45,10 +87,91 @@ func New(modelFlag, validationsFilename, assistantsFilename string) (*Config, er
 		ModelMaxToken: maxTokens,
 		ModelAPIKey:   apiKey,
 		ConfigDir:     configDirPath,
+		Fallback:      settings.Fallback,
+		Audit:         settings.Audit,
+		AutoContinue:  settings.AutoContinue,
+	}, nil
+}
+
+// Dir returns the path of the configuration directory, creating it along with
+// the given configuration files when they do not exist yet.
+func Dir(filenames ...string) (string, error) {
+	configDirPath, err := createConfigDir(configDirName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the '%s' directory: %w", configDirName, err)
+	}
+
+	if err := createConfigFiles(configDirPath, filenames...); err != nil {
+		return "", err
+	}
+
+	return configDirPath, nil
+}
+
+// ReadSettings reads the application settings from the 'config.toml' file of the configuration directory.
+// The file is optional: without it, the settings are the defaults (no fallback, no audit log, no auto continue).
+func ReadSettings(configDirPath string) (*SettingsConfig, error) {
+	v := viper.New()
+	v.SetConfigName(SettingsFileName)
+	v.SetConfigType(configFileExt)
+	v.AddConfigPath(configDirPath)
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return &SettingsConfig{}, nil
+		}
+		return nil, fmt.Errorf("failed to read the '%s.%s' config file: %w", SettingsFileName, configFileExt, err)
+	}
+
+	var sc SettingsConfig
+	if err := v.Unmarshal(&sc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the '%s.%s' config file: %w", SettingsFileName, configFileExt, err)
+	}
+	if sc.AutoContinue < 0 || sc.AutoContinue > maxAutoContinue {
+		return nil, fmt.Errorf("invalid '%s.%s' config: auto_continue must be between 0 and %d", SettingsFileName, configFileExt, maxAutoContinue)
+	}
+
+	return &sc, nil
+}
+
+// FindProjectFile returns the path of the given file in the project directory ('.gail') closest
+// to the working directory, looking up to the root of the filesystem. The path is empty when
+// no project directory has the file.
+func FindProjectFile(filename string) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("cannot get the working directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectDirName, filename)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// NewModelConfig returns the configuration of the model matching the given model flag (e.g. gpt, claude).
+func NewModelConfig(modelFlag string, requireAPIKey bool) (ModelConfig, error) {
+	modelName, maxTokens, apiKey, err := selectModelConfig(modelFlag, requireAPIKey)
+	if err != nil {
+		return ModelConfig{}, err
+	}
+
+	return ModelConfig{
+		Model:     modelName,
+		MaxTokens: maxTokens,
+		APIKey:    apiKey,
 	}, nil
 }
 
-func selectModelConfig(modelFlag string) (models.Model, models.Token, string, error) {
+func selectModelConfig(modelFlag string, requireAPIKey bool) (models.Model, models.Token, string, error) {
 	var modelName models.Model
 	var maxTokens models.Token
 	var apiKey string
@@ -58,37 +181,39 @@ func selectModelConfig(modelFlag string) (models.Model, models.Token, string, er
 		modelName = models.ModelClaudeName
 		maxTokens = models.ModelClaudeMaxTokens
 		apiKey = os.Getenv(envClaudeAPIKey)
-		if apiKey == "" {
+		if apiKey == "" && requireAPIKey {
 			return modelName, maxTokens, apiKey, fmt.Errorf("environment variable '%s' not set for model '%s'", envClaudeAPIKey, models.ModelClaude)
 		}
 	case models.ModelGPT:
 		modelName = models.ModelGPTName
 		maxTokens = models.ModelGPTMaxTokens
-		apiKey = os.Getenv(envOpenAIAPIKey)
-		if apiKey == "" {
-			return modelName, maxTokens, apiKey, fmt.Errorf("environment variable '%s' not set for model '%s'", envOpenAIAPIKey, models.ModelGPT)
+		apiKey = os.Getenv(EnvOpenAIAPIKey)
+		if apiKey == "" && requireAPIKey {
+			return modelName, maxTokens, apiKey, fmt.Errorf("environment variable '%s' not set for model '%s'", EnvOpenAIAPIKey, models.ModelGPT)
 		}
 	case models.ModelGPTo:
 		modelName = models.ModelGPToName
 		maxTokens = models.ModelGPToMaxTokens
-		apiKey = os.Getenv(envOpenAIAPIKey)
-		if apiKey == "" {
-			return modelName, maxTokens, apiKey, fmt.Errorf("environment variable '%s' not set for model '%s'", envOpenAIAPIKey, models.ModelGPTo)
+		apiKey = os.Getenv(EnvOpenAIAPIKey)
+		if apiKey == "" && requireAPIKey {
+			return modelName, maxTokens, apiKey, fmt.Errorf("environment variable '%s' not set for model '%s'", EnvOpenAIAPIKey, models.ModelGPTo)
 		}
+	case models.ModelMock:
+		// The mock model answers from a local script and needs no API key.
+		modelName = models.ModelMockName
+		maxTokens = models.ModelMockMaxTokens
 	default:
-		return modelName, maxTokens, apiKey, fmt.Errorf("invalid model flag '%s'. Use one of ['%s', '%s']", modelFlag, models.ModelClaude, models.ModelGPT)
+		return modelName, maxTokens, apiKey, fmt.Errorf("invalid model flag '%s'. Use one of ['%s', '%s', '%s', '%s']", modelFlag, models.ModelClaude, models.ModelGPT, models.ModelGPTo, models.ModelMock)
 	}
 
 	return modelName, maxTokens, apiKey, nil
 }
 
-func createConfigFiles(configDir, validationsFilename, assistantsFilename string) error {
-	if err := createConfigFile(configDir, validationsFilename); err != nil {
-		return err
-	}
-
-	if err := createConfigFile(configDir, assistantsFilename); err != nil {
-		return err
+func createConfigFiles(configDir string, filenames ...string) error {
+	for _, filename := range filenames {
+		if err := createConfigFile(configDir, filename); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ProviderOpenAI    string = "openai"
+	ProviderAnthropic string = "anthropic"
+)
+
+// ErrorKind classifies an APIError into the common failure cases a user can act upon.
+type ErrorKind string
+
+const (
+	ErrorKindUnknown         ErrorKind = "unknown"
+	ErrorKindInvalidKey      ErrorKind = "invalid_key"
+	ErrorKindQuotaExceeded   ErrorKind = "quota_exceeded"
+	ErrorKindRateLimited     ErrorKind = "rate_limited"
+	ErrorKindOverloaded      ErrorKind = "overloaded"
+	ErrorKindContextTooLong  ErrorKind = "context_too_long"
+	ErrorKindInvalidRequest  ErrorKind = "invalid_request"
+	ErrorKindServerError     ErrorKind = "server_error"
+	ErrorKindNotFound        ErrorKind = "not_found"
+	ErrorKindPermissionError ErrorKind = "permission_error"
+)
+
+// APIError is an error returned by a LLM provider's HTTP API.
+type APIError struct {
+	// The provider that returned the error (e.g. openai, anthropic).
+	Provider string
+	// The HTTP status code of the response.
+	StatusCode int
+	// The provider's error type (e.g. invalid_request_error, overloaded_error).
+	Type string
+	// The provider's error code, when there is one (e.g. insufficient_quota).
+	Code string
+	// The human readable message sent by the provider.
+	Message string
+	// The request ID sent by the provider, useful when contacting their support.
+	RequestID string
+	// Whether sending the same request again later may succeed.
+	Retryable bool
+	// The failure case the error falls into.
+	Kind ErrorKind
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	// The failures reported in the body of a successful response, such as the ones of OpenAI Runs, have no status code.
+	var details []string
+	if e.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status %d", e.StatusCode))
+	}
+	if e.Type != "" {
+		details = append(details, fmt.Sprintf("type %s", e.Type))
+	}
+	if e.Code != "" {
+		details = append(details, fmt.Sprintf("code %s", e.Code))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s API error", e.Provider)
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request id: %s]", e.RequestID)
+	}
+	return b.String()
+}
+
+// openAIErrorBody is the error payload returned by the OpenAI API.
+type openAIErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Param   any    `json:"param"`
+		Code    any    `json:"code"`
+	} `json:"error"`
+}
+
+// anthropicErrorBody is the error payload returned by the Anthropic API.
+type anthropicErrorBody struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewAPIError builds an APIError from its parts, classifying it like the errors parsed from http responses.
+func NewAPIError(provider string, statusCode int, errType string, code string, message string) *APIError {
+	e := &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Type:       errType,
+		Code:       code,
+		Message:    message,
+	}
+
+	e.classify()
+	return e
+}
+
+// NewOpenAIError builds an APIError from a non-successful OpenAI http response.
+func NewOpenAIError(resp *http.Response) *APIError {
+	e := &APIError{
+		Provider:   ProviderOpenAI,
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("x-request-id"),
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		e.Message = fmt.Sprintf("unable to read the http response body: %v", err)
+	}
+
+	var eb openAIErrorBody
+	if err := json.Unmarshal(body, &eb); err == nil && eb.Error.Message != "" {
+		e.Message = eb.Error.Message
+		e.Type = eb.Error.Type
+		if code, ok := eb.Error.Code.(string); ok {
+			e.Code = code
+		}
+	} else if len(body) > 0 {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	e.classify()
+	return e
+}
+
+// NewAnthropicError builds an APIError from a non-successful Anthropic http response.
+func NewAnthropicError(resp *http.Response) *APIError {
+	e := &APIError{
+		Provider:   ProviderAnthropic,
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("request-id"),
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		e.Message = fmt.Sprintf("unable to read the http response body: %v", err)
+	}
+
+	var eb anthropicErrorBody
+	if err := json.Unmarshal(body, &eb); err == nil && eb.Error.Message != "" {
+		e.Message = eb.Error.Message
+		e.Type = eb.Error.Type
+	} else if len(body) > 0 {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	e.classify()
+	return e
+}
+
+// classify sets the Kind and Retryable fields based on the status code, type, code and message.
+func (e *APIError) classify() {
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case e.Code == "context_length_exceeded",
+		strings.Contains(message, "prompt is too long"),
+		strings.Contains(message, "maximum context length"),
+		strings.Contains(message, "context window"):
+		e.Kind = ErrorKindContextTooLong
+	case e.StatusCode == http.StatusUnauthorized,
+		e.Code == "invalid_api_key",
+		e.Type == "authentication_error":
+		e.Kind = ErrorKindInvalidKey
+	case e.Code == "insufficient_quota",
+		strings.Contains(message, "credit balance is too low"):
+		e.Kind = ErrorKindQuotaExceeded
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.Type == "rate_limit_error",
+		e.Code == "rate_limit_exceeded":
+		e.Kind = ErrorKindRateLimited
+		e.Retryable = true
+	// Anthropic returns the non standard 529 status code when its API is overloaded.
+	case e.StatusCode == 529,
+		e.StatusCode == http.StatusServiceUnavailable,
+		e.Type == "overloaded_error":
+		e.Kind = ErrorKindOverloaded
+		e.Retryable = true
+	case e.StatusCode == http.StatusForbidden,
+		e.Type == "permission_error":
+		e.Kind = ErrorKindPermissionError
+	case e.StatusCode == http.StatusNotFound:
+		e.Kind = ErrorKindNotFound
+	case e.StatusCode >= http.StatusInternalServerError,
+		e.Code == "server_error":
+		e.Kind = ErrorKindServerError
+		e.Retryable = true
+	case e.StatusCode == http.StatusBadRequest,
+		e.Type == "invalid_request_error",
+		e.Code == "invalid_prompt":
+		e.Kind = ErrorKindInvalidRequest
+	default:
+		e.Kind = ErrorKindUnknown
+	}
+}
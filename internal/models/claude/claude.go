@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
+	"unicode"
 
 	"log/slog"
 
 	"github.com/nycruz/gail/internal/models"
-	"github.com/nycruz/gail/internal/validator"
+	"github.com/nycruz/gail/internal/schema"
 )
 
 // Claude implements the LLM interface
@@ -26,19 +28,40 @@ type Claude struct {
 	currentRolePersona string
 	// The current instruction used for the chat completion.
 	currentSkillInstruction string
+	// The generation parameters of the last prompt, reused when continuing an answer.
+	currentParams models.Params
+	// The JSON schema of the last prompt, nil for a free form answer.
+	currentSchema *schema.Schema
 	// The Claude API Key
 	apiKey string
 	// Has no meaning or use. Done to satisfy interface implementation.
-	user      string
-	validator *validator.Validator
-	Logger    *slog.Logger
+	user   string
+	Logger *slog.Logger
 }
 
 type MessageRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	System    string    `json:"system"`
-	Messages  []Message `json:"messages"`
+	Model         string      `json:"model"`
+	MaxTokens     int         `json:"max_tokens"`
+	System        string      `json:"system"`
+	Messages      []Message   `json:"messages"`
+	Temperature   *float64    `json:"temperature,omitempty"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Tools         []Tool      `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// Tool is a tool Claude can use. Structured answers are obtained by forcing
+// Claude to use a tool whose input schema is the answer's JSON schema.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 type Message struct {
@@ -49,11 +72,11 @@ type Message struct {
 type MessageResponse struct {
 	ID      string `json:"id"`
 	Content []struct {
-		Text  string `json:"text,omitempty"`
-		ID    string `json:"id,omitempty"`
-		Name  string `json:"name,omitempty"`
-		Input struct {
-		} `json:"input,omitempty"`
+		Type  string          `json:"type"`
+		Text  string          `json:"text,omitempty"`
+		ID    string          `json:"id,omitempty"`
+		Name  string          `json:"name,omitempty"`
+		Input json.RawMessage `json:"input,omitempty"`
 	} `json:"content"`
 	Model        string `json:"model"`
 	StopReason   string `json:"stop_reason"`
@@ -64,7 +87,15 @@ type MessageResponse struct {
 	} `json:"usage"`
 }
 
-func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string, validator *validator.Validator) (*Claude, error) {
+const (
+	contentTypeText     = "text"
+	contentTypeToolUse  = "tool_use"
+	stopReasonMaxTokens = "max_tokens"
+	// The name of the tool used to get structured answers.
+	structuredAnswerTool = "answer"
+)
+
+func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string) (*Claude, error) {
 	claude := &Claude{
 		Model:                   model,
 		apiKey:                  apiKey,
@@ -74,44 +105,132 @@ func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens model
 		messages:                []Message{},
 		currentRolePersona:      "",
 		currentSkillInstruction: "",
-		validator:               validator,
 	}
 
 	return claude, nil
 }
 
-func (c *Claude) Prompt(ctx context.Context, roleName string, rolePersona string, skillInstruction string, message string) (string, error) {
-	validationMsg, isValid := c.validator.Validate(message)
-	if !isValid {
-		return validationMsg, nil
+func (c *Claude) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
+	if request.RolePersona != c.currentRolePersona || request.SkillInstruction != c.currentSkillInstruction {
+		c.currentRolePersona = request.RolePersona
+		c.currentSkillInstruction = request.SkillInstruction
+	}
+	c.currentParams = request.Params
+	c.currentSchema = request.Schema
+
+	// The user message is only kept in the history once it has been answered,
+	// so that retrying a failed prompt does not send it twice.
+	messages := append(c.messages, Message{
+		Role:    "user",
+		Content: request.Message,
+	})
+
+	msr, err := c.sendMessages(ctx, messages)
+	if err != nil {
+		return models.Response{}, err
 	}
 
-	if rolePersona != c.currentRolePersona || skillInstruction != c.currentSkillInstruction {
-		c.currentRolePersona = rolePersona
-		c.currentSkillInstruction = skillInstruction
+	response, err := parseMessageResponse(msr)
+	if err != nil {
+		return models.Response{}, err
 	}
 
-	c.messages = append(c.messages, Message{
-		Role:    "user",
-		Content: message,
+	c.messages = append(messages, Message{
+		Role:    "assistant",
+		Content: response.Text,
+	})
+
+	return response, nil
+}
+
+// Continue asks Claude to carry on with its last answer when it was cut off by the max_tokens limit.
+// The partial answer is sent back as a prefilled assistant message, and the continuation
+// is appended to that same message in the history.
+func (c *Claude) Continue(ctx context.Context) (models.Response, error) {
+	if len(c.messages) == 0 || c.messages[len(c.messages)-1].Role != "assistant" {
+		return models.Response{}, errors.New("there is no previous Claude answer to continue")
+	}
+	// A structured answer is the input of a tool use, which cannot be prefilled.
+	if c.currentSchema != nil {
+		return models.Response{}, models.ErrContinueNotSupported
+	}
+
+	last := c.messages[len(c.messages)-1]
+	// The Messages API rejects a prefilled assistant message ending with whitespace.
+	prefill := strings.TrimRightFunc(last.Content, unicode.IsSpace)
+	trimmed := last.Content[len(prefill):]
+
+	messages := append(c.messages[:len(c.messages)-1:len(c.messages)-1], Message{
+		Role:    "assistant",
+		Content: prefill,
 	})
+	if prefill == "" {
+		// Nothing was answered before the cut off, and the Messages API rejects an empty
+		// prefilled assistant message: the prompt is answered again instead.
+		messages = messages[:len(messages)-1]
+	}
 
+	msr, err := c.sendMessages(ctx, messages)
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	response, err := parseMessageResponse(msr)
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	// Avoid duplicating the whitespace that was trimmed from the prefill.
+	response.Text = strings.TrimPrefix(response.Text, trimmed)
+	c.messages[len(c.messages)-1].Content = last.Content + response.Text
+
+	return response, nil
+}
+
+// SetHistory replaces the conversation history, e.g. when Claude takes over a conversation from another model.
+func (c *Claude) SetHistory(ctx context.Context, history []models.Message) error {
+	messages := make([]Message, 0, len(history))
+	for _, message := range history {
+		messages = append(messages, Message{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+	c.messages = messages
+
+	return nil
+}
+
+// sendMessages sends the conversation to the Claude Messages API.
+func (c *Claude) sendMessages(ctx context.Context, messages []Message) (MessageResponse, error) {
 	messageRequest := MessageRequest{
-		Model:     string(c.Model),
-		MaxTokens: int(c.MaxTokens),
-		System:    fmt.Sprintf("%s. %s", c.currentRolePersona, c.currentSkillInstruction),
-		Messages:  c.messages,
+		Model:         string(c.Model),
+		MaxTokens:     int(c.currentParams.MaxTokensOrDefault(c.MaxTokens)),
+		System:        fmt.Sprintf("%s. %s", c.currentRolePersona, c.currentSkillInstruction),
+		Messages:      messages,
+		Temperature:   c.currentParams.Temperature,
+		TopP:          c.currentParams.TopP,
+		StopSequences: c.currentParams.Stop,
+	}
+
+	if c.currentSchema != nil {
+		messageRequest.Tools = []Tool{{
+			Name:        structuredAnswerTool,
+			Description: "Gives the answer as JSON matching the input schema.",
+			InputSchema: c.currentSchema.Map(),
+		}}
+		messageRequest.ToolChoice = &ToolChoice{Type: "tool", Name: structuredAnswerTool}
 	}
 
 	reqBody, err := json.Marshal(messageRequest)
 	if err != nil {
-		return "", fmt.Errorf("unable to json marshal Claude Message request: %w", err)
+		return MessageResponse{}, fmt.Errorf("unable to json marshal Claude Message request: %w", err)
 	}
 
 	url := "https://api.anthropic.com/v1/messages"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("unable to create Claude Message http request: %w", err)
+		return MessageResponse{}, fmt.Errorf("unable to create Claude Message http request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -120,26 +239,61 @@ func (c *Claude) Prompt(ctx context.Context, roleName string, rolePersona string
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("unable to make Claude Message http request: %w", err)
+		return MessageResponse{}, fmt.Errorf("unable to make Claude Message http request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Claude Message http request failed with unexpected statuscode: %s - %s", resp.Status, string(bodyBytes))
+		return MessageResponse{}, models.NewAnthropicError(resp)
 	}
 
 	var msr MessageResponse
 	if err := json.NewDecoder(resp.Body).Decode(&msr); err != nil {
-		return "", fmt.Errorf("unable to json decode Claude Response http response: %w", err)
+		return MessageResponse{}, fmt.Errorf("unable to json decode Claude Response http response: %w", err)
 	}
 
-	c.messages = append(c.messages, Message{
-		Role:    "assistant",
-		Content: msr.Content[0].Text,
-	})
+	return msr, nil
+}
+
+// parseMessageResponse concatenates all the text blocks of the response, or takes
+// the input of the structured answer tool as the JSON answer, and flags the answer as incomplete when Claude stopped because of the max_tokens limit.
+func parseMessageResponse(msr MessageResponse) (models.Response, error) {
+	var text strings.Builder
+	var structured json.RawMessage
+	for _, content := range msr.Content {
+		switch {
+		case content.Type == contentTypeText:
+			text.WriteString(content.Text)
+		case content.Type == contentTypeToolUse && content.Name == structuredAnswerTool:
+			structured = content.Input
+		}
+	}
+
+	// Claude may explain itself in text blocks before using the tool; the tool input is the answer.
+	answer := text.String()
+	if structured != nil {
+		answer = string(structured)
+	}
+
+	response := models.Response{
+		Text:  answer,
+		Model: msr.Model,
+		Usage: models.Usage{
+			InputTokens:  msr.Usage.InputTokens,
+			OutputTokens: msr.Usage.OutputTokens,
+		},
+	}
+
+	if msr.StopReason == stopReasonMaxTokens {
+		response.Incomplete = true
+		response.IncompleteReason = stopReasonMaxTokens
+		return response, nil
+	}
+
+	if response.Text == "" {
+		return models.Response{}, fmt.Errorf("Claude's response contains no text (stop reason: %s)", msr.StopReason)
+	}
 
-	response := msr.Content[0].Text
 	return response, nil
 }
 
@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/nycruz/gail/internal/cassette"
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/schema"
+)
+
+// replay answers the requests of the test from the cassette of the given directory.
+func replay(t *testing.T, dir string) {
+	t.Helper()
+	replayer, err := cassette.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = replayer
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+}
+
+func newTestClaude(t *testing.T) *Claude {
+	t.Helper()
+	claude, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), "sk-ant-test", models.ModelClaudeName, models.ModelClaudeMaxTokens, "gail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claude
+}
+
+func TestPromptAndContinueAnIncompleteAnswer(t *testing.T) {
+	replay(t, "testdata/continue")
+	claude := newTestClaude(t)
+
+	response, err := claude.Prompt(context.Background(), models.Request{Message: "How do I build a Go program?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Here are the steps:\n1. Install Go.\n2. Run"; response.Text != want {
+		t.Errorf("Text = %q, want %q", response.Text, want)
+	}
+	if !response.Incomplete || response.IncompleteReason != stopReasonMaxTokens {
+		t.Errorf("Incomplete = %t (%s), want an answer cut off by %s", response.Incomplete, response.IncompleteReason, stopReasonMaxTokens)
+	}
+
+	continued, err := claude.Continue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if continued.Text != " go build." || continued.Incomplete {
+		t.Errorf("Continue() = %q (incomplete: %t), want the rest of the answer", continued.Text, continued.Incomplete)
+	}
+
+	last := claude.messages[len(claude.messages)-1]
+	if want := "Here are the steps:\n1. Install Go.\n2. Run go build."; last.Role != "assistant" || last.Content != want {
+		t.Errorf("last message = %+v, want the whole assistant answer %q", last, want)
+	}
+}
+
+func TestPromptStructuredAnswer(t *testing.T) {
+	replay(t, "testdata/structured")
+	claude := newTestClaude(t)
+
+	s, err := schema.Parse("findings", `{"type": "object", "properties": {"findings": {"type": "array"}}, "required": ["findings"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := claude.Prompt(context.Background(), models.Request{Message: "Review this code", Schema: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"findings":[{"severity":"medium","line":3,"description":"The error is ignored."}]}`; response.Text != want {
+		t.Errorf("Text = %s, want the input of the tool %s", response.Text, want)
+	}
+}
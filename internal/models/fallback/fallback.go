@@ -0,0 +1,149 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nycruz/gail/internal/models"
+)
+
+// Interface Guard
+var _ models.LLM = (*Fallback)(nil)
+
+// Fallback implements the LLM interface over an ordered chain of models.
+// Every prompt is sent to the first model of the chain; when it fails with a
+// retryable error, the prompt is sent again to the next model along with the
+// conversation held so far.
+type Fallback struct {
+	// The ordered chain of models. The first one is the preferred model.
+	chain []models.LLM
+	// The conversation held so far, whichever model answered.
+	history []models.Message
+	// The number of history messages known by each model of the chain.
+	synced []int
+	// The index of the model that gave the last answer.
+	lastAnswered int
+	// The logger used for logging messages.
+	Logger *slog.Logger
+}
+
+// New creates a Fallback over the given chain of models.
+func New(logger *slog.Logger, chain []models.LLM) (*Fallback, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("the fallback chain has no model")
+	}
+
+	f := &Fallback{
+		chain:  chain,
+		synced: make([]int, len(chain)),
+		Logger: logger,
+	}
+
+	return f, nil
+}
+
+func (f *Fallback) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
+	var errs []error
+	for i, llm := range f.chain {
+		if err := f.syncHistory(ctx, i); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		response, err := llm.Prompt(ctx, request)
+		if err == nil && response.Blocked {
+			// The prompt was not sent, so it is not part of the conversation.
+			return response, nil
+		}
+		if err == nil {
+			if response.Model == "" {
+				response.Model = llm.GetModel()
+			}
+			response.FallbackIndex = i
+			f.history = append(f.history,
+				models.Message{Role: models.RoleUser, Content: request.Message},
+				models.Message{Role: models.RoleAssistant, Content: response.Text},
+			)
+			f.synced[i] = len(f.history)
+			f.lastAnswered = i
+			return response, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", llm.GetModel(), err))
+		if !isRetryable(err) || i == len(f.chain)-1 {
+			break
+		}
+
+		f.Logger.Warn(
+			"Model failed, falling back to the next one.",
+			slog.String("model", llm.GetModel()),
+			slog.String("fallback", f.chain[i+1].GetModel()),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return models.Response{}, errors.Join(errs...)
+}
+
+// Continue asks the model that gave the last answer to carry on with it.
+func (f *Fallback) Continue(ctx context.Context) (models.Response, error) {
+	llm := f.chain[f.lastAnswered]
+
+	response, err := llm.Continue(ctx)
+	if err != nil {
+		return models.Response{}, err
+	}
+	if response.Model == "" {
+		response.Model = llm.GetModel()
+	}
+	response.FallbackIndex = f.lastAnswered
+
+	if len(f.history) > 0 {
+		f.history[len(f.history)-1].Content += response.Text
+		f.synced[f.lastAnswered] = len(f.history)
+	}
+
+	return response, nil
+}
+
+// GetModel returns the preferred model of the chain.
+func (f *Fallback) GetModel() string {
+	return f.chain[0].GetModel()
+}
+
+// GetUser returns the user of the preferred model of the chain.
+func (f *Fallback) GetUser() string {
+	return f.chain[0].GetUser()
+}
+
+// syncHistory hands the conversation over to the model at the given index
+// when it missed answers given by other models.
+func (f *Fallback) syncHistory(ctx context.Context, index int) error {
+	if f.synced[index] == len(f.history) {
+		return nil
+	}
+
+	llm := f.chain[index]
+	hs, ok := llm.(models.HistorySetter)
+	if !ok {
+		return fmt.Errorf("%s: cannot take over the conversation from another model", llm.GetModel())
+	}
+
+	if err := hs.SetHistory(ctx, f.history); err != nil {
+		return fmt.Errorf("%s: failed to take over the conversation: %w", llm.GetModel(), err)
+	}
+	f.synced[index] = len(f.history)
+
+	return nil
+}
+
+// isRetryable reports whether the error is worth sending the prompt to another model.
+func isRetryable(err error) bool {
+	var apiErr *models.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable
+	}
+	return false
+}
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
+
+	"github.com/nycruz/gail/internal/models"
 )
 
 type AssistantRequest struct {
@@ -73,11 +73,7 @@ func (gpt *GPT) createAssistant(ctx context.Context, roleName string, persona st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", errors.New("unable to read the http response body")
-		}
-		return "", fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+		return "", models.NewOpenAIError(resp)
 	}
 
 	var assistantResponse AssistantResponse
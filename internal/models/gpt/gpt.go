@@ -4,11 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"log/slog"
 
 	"github.com/nycruz/gail/internal/models"
-	"github.com/nycruz/gail/internal/validator"
 )
 
 // GPT implements the LLM interface
@@ -30,13 +30,16 @@ type GPT struct {
 	currentRolePersona string
 	// The current instruction used for the chat completion.
 	currentSkillInstruction string
-	// The validator used to validate the input message.
-	validator *validator.Validator
+	// The time waited between two polls of a Run, e.g. 0 when the responses are replayed.
+	PollInterval time.Duration
 	// The logger used for logging messages.
 	Logger *slog.Logger
 }
 
-func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string, validator *validator.Validator) (*GPT, error) {
+// defaultPollInterval is the time waited between two polls of a Run.
+const defaultPollInterval = 4 * time.Second
+
+func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string) (*GPT, error) {
 	threadID, err := createThread(apiKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not to create an OpenAI Thread: %w", err)
@@ -50,58 +53,83 @@ func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens model
 		ThreadID:                threadID,
 		currentRolePersona:      "",
 		currentSkillInstruction: "",
-		validator:               validator,
+		PollInterval:            defaultPollInterval,
 		Logger:                  logger,
 	}
 
 	return gpt, nil
 }
 
-func (gpt *GPT) Prompt(ctx context.Context, roleName string, rolePersona string, skillInstruction string, message string) (string, error) {
-	validationMsg, isValid := gpt.validator.Validate(message)
-	if !isValid {
-		return validationMsg, nil
-	}
-
+func (gpt *GPT) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
 	if gpt.ThreadID == "" {
-		return "", errors.New("OpenAI's Thread ID is empty. No Thread has been created")
+		return models.Response{}, errors.New("OpenAI's Thread ID is empty. No Thread has been created")
 	}
 
-	if rolePersona != gpt.currentRolePersona || skillInstruction != gpt.currentSkillInstruction {
-		assistantID, err := gpt.createAssistant(ctx, roleName, rolePersona, skillInstruction)
+	if request.RolePersona != gpt.currentRolePersona || request.SkillInstruction != gpt.currentSkillInstruction {
+		assistantID, err := gpt.createAssistant(ctx, request.RoleName, request.RolePersona, request.SkillInstruction)
 		if err != nil {
-			return "", fmt.Errorf("failed to create an OpenAI Assistant: %w", err)
+			return models.Response{}, fmt.Errorf("failed to create an OpenAI Assistant: %w", err)
 		}
 
 		gpt.AssistantID = assistantID
-		gpt.currentRolePersona = rolePersona
-		gpt.currentSkillInstruction = skillInstruction
+		gpt.currentRolePersona = request.RolePersona
+		gpt.currentSkillInstruction = request.SkillInstruction
 	}
 
 	if gpt.AssistantID == "" {
-		return "", errors.New("OpenAI's Assistant ID is empty. No Assistant has been created")
+		return models.Response{}, errors.New("OpenAI's Assistant ID is empty. No Assistant has been created")
 	}
 
-	if err := gpt.createMessage(ctx, message); err != nil {
-		return "", fmt.Errorf("failed to create an OpenAI Message: %w", err)
+	if err := gpt.createMessage(ctx, models.RoleUser, request.Message); err != nil {
+		return models.Response{}, fmt.Errorf("failed to create an OpenAI Message: %w", err)
 	}
 
-	runID, err := gpt.createRun(ctx)
+	runID, err := gpt.createRun(ctx, request.Params, request.Schema)
 	if err != nil {
-		return "", fmt.Errorf("failed to create an OpenAI Run: %w", err)
+		return models.Response{}, fmt.Errorf("failed to create an OpenAI Run: %w", err)
 	}
 
-	if err := gpt.waitRunCompleted(ctx, runID); err != nil {
-		return "", fmt.Errorf("failed to poll an OpenAI Run: %w", err)
+	run, err := gpt.waitRunEnded(ctx, runID)
+	if err != nil {
+		return models.Response{}, fmt.Errorf("failed to poll an OpenAI Run: %w", err)
 	}
 
 	response, err := gpt.getResponse(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get OpenAI's response: %w", err)
+		return models.Response{}, fmt.Errorf("failed to get OpenAI's response: %w", err)
 	}
 
-	return response, nil
+	reason := run.incompleteReason()
+	return models.Response{
+		Text:             response,
+		Incomplete:       reason != "",
+		IncompleteReason: reason,
+		Usage:            run.usage(),
+		Model:            string(gpt.Model),
+	}, nil
+}
+
+// SetHistory starts a new OpenAI Thread holding the given conversation,
+// e.g. when ChatGPT takes over a conversation from another model.
+func (gpt *GPT) SetHistory(ctx context.Context, history []models.Message) error {
+	threadID, err := createThread(gpt.apiKey)
+	if err != nil {
+		return fmt.Errorf("could not to create an OpenAI Thread: %w", err)
+	}
+	gpt.ThreadID = threadID
+
+	for _, message := range history {
+		if err := gpt.createMessage(ctx, message.Role, message.Content); err != nil {
+			return fmt.Errorf("failed to create an OpenAI Message: %w", err)
+		}
+	}
+
+	return nil
+}
 
+// Continue is not supported by OpenAI Assistants.
+func (gpt *GPT) Continue(ctx context.Context) (models.Response, error) {
+	return models.Response{}, models.ErrContinueNotSupported
 }
 
 // GetModel returns the model used for the chat completion.
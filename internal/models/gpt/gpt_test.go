@@ -0,0 +1,92 @@
+package gpt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/nycruz/gail/internal/cassette"
+	"github.com/nycruz/gail/internal/models"
+)
+
+// replay answers the requests of the test from the cassette of the given directory.
+func replay(t *testing.T, dir string) {
+	t.Helper()
+	replayer, err := cassette.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = replayer
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+}
+
+// newTestGPT creates a GPT polling its Runs without waiting.
+func newTestGPT(t *testing.T) *GPT {
+	t.Helper()
+	gpt, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), "sk-test", models.ModelGPTName, models.ModelGPTMaxTokens, "gail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpt.PollInterval = 0
+	return gpt
+}
+
+var testRequest = models.Request{
+	RoleName:         "Software Engineer",
+	RolePersona:      "You are a Software Engineer",
+	SkillInstruction: "Be concise",
+	Message:          "How do I compare wrapped errors in Go?",
+}
+
+func TestPromptPollsTheRunUntilCompleted(t *testing.T) {
+	replay(t, "testdata/prompt")
+	gpt := newTestGPT(t)
+
+	response, err := gpt.Prompt(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Use errors.Is to compare wrapped errors."; response.Text != want {
+		t.Errorf("Text = %q, want %q", response.Text, want)
+	}
+	if want := (models.Usage{InputTokens: 42, OutputTokens: 17}); response.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", response.Usage, want)
+	}
+	if gpt.ThreadID != "thread_abc123" || gpt.AssistantID != "asst_abc123" {
+		t.Errorf("ThreadID = %q, AssistantID = %q", gpt.ThreadID, gpt.AssistantID)
+	}
+}
+
+func TestPromptReturnsTheRunError(t *testing.T) {
+	replay(t, "testdata/run-failed")
+	gpt := newTestGPT(t)
+
+	_, err := gpt.Prompt(context.Background(), testRequest)
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.Kind != models.ErrorKindRateLimited || !apiErr.Retryable {
+		t.Errorf("Kind = %s, Retryable = %t, want a retryable %s error", apiErr.Kind, apiErr.Retryable, models.ErrorKindRateLimited)
+	}
+}
+
+func TestPromptReturnsAnIncompleteRun(t *testing.T) {
+	replay(t, "testdata/run-incomplete")
+	gpt := newTestGPT(t)
+
+	response, err := gpt.Prompt(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Text != "Use errors.Is to compare" || !response.Incomplete || response.IncompleteReason != "max_completion_tokens" {
+		t.Errorf("Prompt() = %+v, want an answer cut off by max_completion_tokens", response)
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
+
+	"github.com/nycruz/gail/internal/models"
 )
 
 type MessageRequest struct {
@@ -61,10 +61,10 @@ type MessagesResponse struct {
 	HasMore bool   `json:"has_more"`
 }
 
-// createMessage creates a new OpenAI Message with the given content.
-func (gpt *GPT) createMessage(ctx context.Context, message string) error {
+// createMessage creates a new OpenAI Message with the given role and content.
+func (gpt *GPT) createMessage(ctx context.Context, role string, message string) error {
 	messageRequest := MessageRequest{
-		Role:    "user",
+		Role:    role,
 		Content: message,
 	}
 
@@ -90,11 +90,7 @@ func (gpt *GPT) createMessage(ctx context.Context, message string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errors.New("unable to read the http response body")
-		}
-		return fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+		return models.NewOpenAIError(resp)
 	}
 
 	return nil
@@ -119,11 +115,7 @@ func (gpt *GPT) getResponse(ctx context.Context) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", errors.New("unable to read the http response body")
-		}
-		return "", fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+		return "", models.NewOpenAIError(resp)
 	}
 
 	var msr MessagesResponse
@@ -4,30 +4,52 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/schema"
 )
 
 type RunRequest struct {
-	AssistantID string `json:"assistant_id"`
+	AssistantID         string          `json:"assistant_id"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat asks the Run to answer with JSON matching a schema.
+type ResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string         `json:"name"`
+		Schema map[string]any `json:"schema"`
+		Strict bool           `json:"strict"`
+	} `json:"json_schema"`
 }
 
 type RunResponse struct {
-	ID           string `json:"id"`
-	Object       string `json:"object"`
-	CreatedAt    int    `json:"created_at"`
-	AssistantID  string `json:"assistant_id"`
-	ThreadID     string `json:"thread_id"`
-	Status       string `json:"status"`
-	StartedAt    int    `json:"started_at"`
-	ExpiresAt    any    `json:"expires_at"`
-	CancelledAt  any    `json:"cancelled_at"`
-	FailedAt     any    `json:"failed_at"`
-	CompletedAt  int    `json:"completed_at"`
-	LastError    any    `json:"last_error"`
+	ID          string `json:"id"`
+	Object      string `json:"object"`
+	CreatedAt   int    `json:"created_at"`
+	AssistantID string `json:"assistant_id"`
+	ThreadID    string `json:"thread_id"`
+	Status      string `json:"status"`
+	StartedAt   int    `json:"started_at"`
+	ExpiresAt   any    `json:"expires_at"`
+	CancelledAt any    `json:"cancelled_at"`
+	FailedAt    any    `json:"failed_at"`
+	CompletedAt int    `json:"completed_at"`
+	// Set when the Run stopped before the end of the answer, e.g. with the reason max_completion_tokens.
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	LastError *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"last_error"`
 	Model        string `json:"model"`
 	Instructions any    `json:"instructions"`
 	Tools        []struct {
@@ -36,11 +58,32 @@ type RunResponse struct {
 	FileIds  []string `json:"file_ids"`
 	Metadata struct {
 	} `json:"metadata"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
 }
 
-func (gpt *GPT) createRun(ctx context.Context) (string, error) {
+// createRun starts a Run of the Assistant on the Thread with the given generation parameters,
+// answering with JSON matching the given schema when there is one.
+// Runs do not support stop sequences, so they are ignored.
+func (gpt *GPT) createRun(ctx context.Context, params models.Params, jsonSchema *schema.Schema) (string, error) {
+	if len(params.Stop) > 0 {
+		gpt.Logger.Warn("GPT: stop sequences are not supported by OpenAI Assistants Runs and are ignored.")
+	}
+
 	runRequest := RunRequest{
-		AssistantID: gpt.AssistantID,
+		AssistantID:         gpt.AssistantID,
+		Temperature:         params.Temperature,
+		TopP:                params.TopP,
+		MaxCompletionTokens: int(params.MaxTokens),
+	}
+
+	if jsonSchema != nil {
+		format := &ResponseFormat{Type: "json_schema"}
+		format.JSONSchema.Name = jsonSchema.Name
+		format.JSONSchema.Schema = jsonSchema.Map()
+		runRequest.ResponseFormat = format
 	}
 
 	reqBody, err := json.Marshal(runRequest)
@@ -65,11 +108,7 @@ func (gpt *GPT) createRun(ctx context.Context) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", errors.New("unable to read the http response body")
-		}
-		return "", fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+		return "", models.NewOpenAIError(resp)
 	}
 
 	var rr RunResponse
@@ -80,18 +119,27 @@ func (gpt *GPT) createRun(ctx context.Context) (string, error) {
 	return rr.ID, nil
 }
 
-func (gpt *GPT) waitRunCompleted(ctx context.Context, runID string) error {
+// The statuses of a Run that has ended.
+const (
+	runStatusCompleted  = "completed"
+	runStatusIncomplete = "incomplete"
+	runStatusFailed     = "failed"
+	runStatusCancelled  = "cancelled"
+	runStatusExpired    = "expired"
+)
+
+// waitRunEnded polls the Run until it has ended and returns it when it is completed or incomplete.
+// The failed, cancelled and expired Runs are returned as errors.
+func (gpt *GPT) waitRunEnded(ctx context.Context, runID string) (RunResponse, error) {
 	retryLimit := 20
-	waitTime := 4 * time.Second
+	waitTime := gpt.PollInterval
 	retryCount := 0
-	isCompleted := false
-	completedStatus := "completed"
 
-	for !isCompleted && retryCount < retryLimit {
+	for retryCount < retryLimit {
 		url := fmt.Sprintf("https://api.openai.com/v1/threads/%s/runs/%s", gpt.ThreadID, runID)
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if err != nil {
-			return fmt.Errorf("unable to create the http request: %w", err)
+			return RunResponse{}, fmt.Errorf("unable to create the http request: %w", err)
 		}
 
 		req.Header.Set("Authorization", "Bearer "+gpt.apiKey)
@@ -100,32 +148,73 @@ func (gpt *GPT) waitRunCompleted(ctx context.Context, runID string) error {
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("unable to make the http request: %w", err)
+			return RunResponse{}, fmt.Errorf("unable to make the http request: %w", err)
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return errors.New("unable to read the http response body")
-			}
-			return fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+			return RunResponse{}, models.NewOpenAIError(resp)
 		}
 
 		var rr RunResponse
 		if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
-			return fmt.Errorf("unable to decode the response body: %w", err)
+			return RunResponse{}, fmt.Errorf("unable to decode the response body: %w", err)
 		}
 
-		gpt.Logger.Info("GPT: WaitRunCompleted", "run_num", retryCount, "status", rr.Status)
+		gpt.Logger.Info("GPT: WaitRunEnded", "run_num", retryCount, "status", rr.Status)
 
-		if rr.Status == completedStatus {
-			return nil
+		switch rr.Status {
+		case runStatusCompleted, runStatusIncomplete:
+			return rr, nil
+		case runStatusFailed:
+			if rr.LastError != nil {
+				return RunResponse{}, models.NewAPIError(models.ProviderOpenAI, 0, "", rr.LastError.Code, rr.LastError.Message)
+			}
+			return RunResponse{}, fmt.Errorf("Run has status '%s'", rr.Status)
+		case runStatusCancelled, runStatusExpired:
+			return RunResponse{}, fmt.Errorf("Run has status '%s'", rr.Status)
 		}
 
-		time.Sleep(waitTime)
+		if err := sleep(ctx, waitTime); err != nil {
+			return RunResponse{}, err
+		}
 		retryCount++
 	}
 
-	return fmt.Errorf("Run did not complete with status '%s' after %d retries every %d seconds.", completedStatus, retryLimit, waitTime)
+	return RunResponse{}, fmt.Errorf("Run did not end after %d retries every %s.", retryLimit, waitTime)
+}
+
+// usage returns the tokens consumed by the Run.
+func (rr RunResponse) usage() models.Usage {
+	var usage models.Usage
+	if rr.Usage != nil {
+		usage.InputTokens = rr.Usage.PromptTokens
+		usage.OutputTokens = rr.Usage.CompletionTokens
+	}
+	return usage
+}
+
+// incompleteReason returns why the Run stopped before the end of the answer, empty when it is completed.
+func (rr RunResponse) incompleteReason() string {
+	if rr.Status != runStatusIncomplete {
+		return ""
+	}
+	if rr.IncompleteDetails == nil || rr.IncompleteDetails.Reason == "" {
+		return runStatusIncomplete
+	}
+	return rr.IncompleteDetails.Reason
+}
+
+// sleep waits for the given duration, unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
 }
@@ -3,9 +3,9 @@ package gpt
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
+
+	"github.com/nycruz/gail/internal/models"
 )
 
 type ThreadResponse struct {
@@ -34,18 +34,12 @@ func createThread(apiKey string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		errorContent, _ := errorPrettyPrint(respBody)
-		return "", fmt.Errorf("error making a http request. Statuscode: %s. Error: %s", resp.Status, errorContent)
+		return "", models.NewOpenAIError(resp)
 	}
 
 	var threadResponse ThreadResponse
-	if err := json.Unmarshal(respBody, &threadResponse); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&threadResponse); err != nil {
 		return "", err
 	}
 
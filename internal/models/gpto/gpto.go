@@ -2,11 +2,12 @@ package gpto
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/nycruz/gail/internal/models"
-	"github.com/nycruz/gail/internal/validator"
+	"github.com/nycruz/gail/internal/schema"
 )
 
 // GPTO implements the LLM interface
@@ -20,39 +21,86 @@ type GPTO struct {
 	MaxTokens models.Token
 	// The OpenAI API key used for authentication.
 	apiKey string
-	// The validator used to validate the input message.
-	validator *validator.Validator
+	// The instructions sent with the last prompt, reused when continuing an answer.
+	lastInstructions string
+	// The generation parameters of the last prompt, reused when continuing an answer.
+	lastParams models.Params
+	// The JSON schema of the last prompt, nil for a free form answer.
+	lastSchema *schema.Schema
+	// The ID of the last response, used to continue an incomplete answer.
+	lastResponseID string
+	// The conversation held with another model, sent along with the next prompt.
+	history []models.Message
 	// The logger used for logging messages.
 	Logger *slog.Logger
 }
 
-func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string, validator *validator.Validator) (*GPTO, error) {
+// continuePrompt is sent to the model to resume an answer that was cut off.
+const continuePrompt = "Continue exactly where your previous answer stopped, without repeating anything."
+
+func New(logger *slog.Logger, apiKey string, model models.Model, maxTokens models.Token, user string) (*GPTO, error) {
 	gpto := &GPTO{
 		Model:     model,
 		User:      user,
 		apiKey:    apiKey,
 		MaxTokens: maxTokens,
-		validator: validator,
 		Logger:    logger,
 	}
 
 	return gpto, nil
 }
 
-func (gpto *GPTO) Prompt(ctx context.Context, roleName string, rolePersona string, skillInstruction string, message string) (string, error) {
-	validationMsg, isValid := gpto.validator.Validate(message)
-	if !isValid {
-		return validationMsg, nil
+func (gpto *GPTO) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
+	gpto.warnUnsupportedParams(request.Params)
+
+	instructions := fmt.Sprintf("%s. %s.", request.RolePersona, request.SkillInstruction)
+	response, err := gpto.response(ctx, instructions, request.Message, "", request.Params, request.Schema, "high")
+	if err != nil {
+		return models.Response{}, fmt.Errorf("failed to get OpenAI's response: %w", err)
+	}
+	gpto.lastInstructions = instructions
+	gpto.lastParams = request.Params
+	gpto.lastSchema = request.Schema
+
+	return response, nil
+}
+
+// Continue asks the model to carry on with its last, incomplete, answer.
+func (gpto *GPTO) Continue(ctx context.Context) (models.Response, error) {
+	if gpto.lastResponseID == "" {
+		return models.Response{}, errors.New("there is no previous OpenAI response to continue")
+	}
+	// A structured answer cannot be resumed, the model would start a new JSON document.
+	if gpto.lastSchema != nil {
+		return models.Response{}, models.ErrContinueNotSupported
 	}
 
-	response, err := gpto.response(ctx, rolePersona, skillInstruction, message, "high")
+	response, err := gpto.response(ctx, gpto.lastInstructions, continuePrompt, gpto.lastResponseID, gpto.lastParams, nil, "high")
 	if err != nil {
-		return "", fmt.Errorf("failed to get OpenAI's response: %w", err)
+		return models.Response{}, fmt.Errorf("failed to continue OpenAI's response: %w", err)
 	}
 
 	return response, nil
 }
 
+// SetHistory keeps the given conversation to send it along with the next prompt,
+// e.g. when ChatGPT-o takes over a conversation from another model.
+func (gpto *GPTO) SetHistory(ctx context.Context, history []models.Message) error {
+	gpto.history = history
+	return nil
+}
+
+// warnUnsupportedParams logs the generation parameters that reasoning models do not accept.
+// Only the maximum number of output tokens is sent to the model.
+func (gpto *GPTO) warnUnsupportedParams(params models.Params) {
+	if params.Temperature != nil || params.TopP != nil || len(params.Stop) > 0 {
+		gpto.Logger.Warn(
+			"GPTO: temperature, top_p and stop sequences are not supported by reasoning models and are ignored.",
+			slog.String("model", string(gpto.Model)),
+		)
+	}
+}
+
 func (gpto *GPTO) GetModel() string {
 	return string(gpto.Model)
 }
@@ -0,0 +1,76 @@
+package gpto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/nycruz/gail/internal/cassette"
+	"github.com/nycruz/gail/internal/models"
+)
+
+// replay answers the requests of the test from the cassette of the given directory.
+func replay(t *testing.T, dir string) {
+	t.Helper()
+	replayer, err := cassette.NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = replayer
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+}
+
+func newTestGPTO(t *testing.T) *GPTO {
+	t.Helper()
+	gpto, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), "sk-test", models.ModelGPToName, models.ModelGPToMaxTokens, "gail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gpto
+}
+
+func TestPromptAndContinueAnIncompleteAnswer(t *testing.T) {
+	replay(t, "testdata/continue")
+	gpto := newTestGPTO(t)
+
+	response, err := gpto.Prompt(context.Background(), models.Request{Message: "What is a goroutine?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "A goroutine is a lightweight" || !response.Incomplete || response.IncompleteReason != "max_output_tokens" {
+		t.Errorf("Prompt() = %+v, want an answer cut off by max_output_tokens", response)
+	}
+	if gpto.lastResponseID != "resp_incomplete" {
+		t.Errorf("lastResponseID = %q, want the ID of the incomplete response", gpto.lastResponseID)
+	}
+
+	continued, err := gpto.Continue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if continued.Text != " thread managed by the Go runtime." || continued.Incomplete {
+		t.Errorf("Continue() = %+v, want the rest of the answer", continued)
+	}
+	if want := (models.Usage{InputTokens: 90, OutputTokens: 12}); continued.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", continued.Usage, want)
+	}
+}
+
+func TestPromptReturnsTheResponseError(t *testing.T) {
+	replay(t, "testdata/failed")
+	gpto := newTestGPTO(t)
+
+	_, err := gpto.Prompt(context.Background(), models.Request{Message: "What is a goroutine?"})
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.Kind != models.ErrorKindServerError || !apiErr.Retryable {
+		t.Errorf("Kind = %s, Retryable = %t, want a retryable %s error", apiErr.Kind, apiErr.Retryable, models.ErrorKindServerError)
+	}
+}
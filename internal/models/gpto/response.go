@@ -6,26 +6,59 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/schema"
 )
 
 type ResponseRequest struct {
-	Model           string `json:"model"`
-	Instructions    string `json:"instructions"`
-	Input           string `json:"input"`
-	User            string `json:"user"`
-	MaxOutputTokens int    `json:"max_output_tokens"`
-	Reasoning       struct {
+	Model        string `json:"model"`
+	Instructions string `json:"instructions"`
+	// Input is either the prompt as a string or a list of InputMessage holding a conversation.
+	Input              any    `json:"input"`
+	User               string `json:"user"`
+	MaxOutputTokens    int    `json:"max_output_tokens"`
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	Reasoning          struct {
 		Effort string `json:"effort"` // Effort can be "low", "medium", or "high"
 	} `json:"reasoning"`
+	Text *TextOptions `json:"text,omitempty"`
+}
+
+// TextOptions configures the text output, e.g. to get JSON matching a schema.
+type TextOptions struct {
+	Format TextFormat `json:"format"`
+}
+
+type TextFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+	Strict bool           `json:"strict"`
+}
+
+type InputMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 type ResponseResponse struct {
-	Status            string `json:"status"`
-	Error             any    `json:"error"`
-	IncompleteDetails any    `json:"incomplete_details"`
-	Output            []struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Output []struct {
 		Type    string `json:"type"`
 		ID      string `json:"id"`
 		Status  string `json:"status"`
@@ -33,18 +66,38 @@ type ResponseResponse struct {
 		Content []struct {
 			Type        string `json:"type"`
 			Text        string `json:"text"`
+			Refusal     string `json:"refusal"`
 			Annotations []any  `json:"annotations"`
 		} `json:"content"`
 	} `json:"output"`
 }
 
-func (gpto *GPTO) response(ctx context.Context, persona string, instruction string, message string, effort string) (string, error) {
+const (
+	responseStatusCompleted  = "completed"
+	responseStatusIncomplete = "incomplete"
+
+	outputTypeMessage     = "message"
+	contentTypeOutputText = "output_text"
+	contentTypeRefusal    = "refusal"
+)
+
+func (gpto *GPTO) response(ctx context.Context, instructions string, message string, previousResponseID string, params models.Params, jsonSchema *schema.Schema, effort string) (models.Response, error) {
+	var input any = message
+	if len(gpto.history) > 0 && previousResponseID == "" {
+		messages := make([]InputMessage, 0, len(gpto.history)+1)
+		for _, m := range gpto.history {
+			messages = append(messages, InputMessage{Role: m.Role, Content: m.Content})
+		}
+		input = append(messages, InputMessage{Role: models.RoleUser, Content: message})
+	}
+
 	responseRequest := ResponseRequest{
-		Model:           string(gpto.Model),
-		Instructions:    fmt.Sprintf("%s. %s.", persona, instruction),
-		Input:           message,
-		User:            gpto.User,
-		MaxOutputTokens: int(gpto.MaxTokens),
+		Model:              string(gpto.Model),
+		Instructions:       instructions,
+		Input:              input,
+		User:               gpto.User,
+		MaxOutputTokens:    int(params.MaxTokensOrDefault(gpto.MaxTokens)),
+		PreviousResponseID: previousResponseID,
 		Reasoning: struct {
 			Effort string `json:"effort"`
 		}{
@@ -52,15 +105,27 @@ func (gpto *GPTO) response(ctx context.Context, persona string, instruction stri
 		},
 	}
 
+	// Strict mode is not requested as it only accepts a subset of JSON Schema;
+	// the answer is validated against the schema once received instead.
+	if jsonSchema != nil {
+		responseRequest.Text = &TextOptions{
+			Format: TextFormat{
+				Type:   "json_schema",
+				Name:   jsonSchema.Name,
+				Schema: jsonSchema.Map(),
+			},
+		}
+	}
+
 	reqBody, err := json.Marshal(responseRequest)
 	if err != nil {
-		return "", fmt.Errorf("unable to json marshal the request: %w", err)
+		return models.Response{}, fmt.Errorf("unable to json marshal the request: %w", err)
 	}
 
 	const url = "https://api.openai.com/v1/responses"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("unable to create the http request: %w", err)
+		return models.Response{}, fmt.Errorf("unable to create the http request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+gpto.apiKey)
@@ -68,46 +133,87 @@ func (gpto *GPTO) response(ctx context.Context, persona string, instruction stri
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("unable to make the http request: %w", err)
+		return models.Response{}, fmt.Errorf("unable to make the http request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", errors.New("unable to read the http response body")
-		}
-		return "", fmt.Errorf("the http request failed with unexpected statuscode: %s. %s", resp.Status, string(bodyBytes))
+		return models.Response{}, models.NewOpenAIError(resp)
 	}
 
 	var responseResponse ResponseResponse
 	if err := json.NewDecoder(resp.Body).Decode(&responseResponse); err != nil {
-		return "", fmt.Errorf("unable to json decode the response body: %w", err)
+		return models.Response{}, fmt.Errorf("unable to json decode the response body: %w", err)
 	}
 
-	response, err := validateResponse(&responseResponse)
+	response, err := parseResponse(&responseResponse)
 	if err != nil {
-		return "", fmt.Errorf("unable to get the answer from the response: %w", err)
+		return models.Response{}, fmt.Errorf("unable to get the answer from the response: %w", err)
 	}
 
+	response.Model = string(gpto.Model)
+	gpto.lastResponseID = responseResponse.ID
+	// The carried over history is only sent along with the prompt following it.
+	gpto.history = nil
+
 	return response, nil
 }
 
-// validateResponse checks if the response is valid and returns the answer as expected
-func validateResponse(r *ResponseResponse) (string, error) {
+// parseResponse walks the output items by type and assembles the answer.
+// All "output_text" parts are concatenated; reasoning items, tool calls and
+// other item types are skipped.
+func parseResponse(r *ResponseResponse) (models.Response, error) {
 	if r == nil {
-		return "", fmt.Errorf("nil response")
+		return models.Response{}, errors.New("nil response")
+	}
+
+	if r.Status != responseStatusCompleted && r.Status != responseStatusIncomplete {
+		if r.Error != nil {
+			return models.Response{}, models.NewAPIError(models.ProviderOpenAI, 0, "", r.Error.Code, r.Error.Message)
+		}
+		return models.Response{}, fmt.Errorf("OpenAI's response has status '%s'", r.Status)
+	}
+
+	var texts, refusals []string
+	for _, output := range r.Output {
+		if output.Type != outputTypeMessage {
+			continue
+		}
+
+		var text strings.Builder
+		for _, content := range output.Content {
+			switch content.Type {
+			case contentTypeOutputText:
+				text.WriteString(content.Text)
+			case contentTypeRefusal:
+				refusals = append(refusals, content.Refusal)
+			}
+		}
+		if text.Len() > 0 {
+			texts = append(texts, text.String())
+		}
 	}
-	if len(r.Output) <= 1 {
-		return "", fmt.Errorf("expected at least 2 Outputs from OpenAI, got %d", len(r.Output))
+
+	response := models.Response{
+		Text:    strings.Join(texts, "\n\n"),
+		Refusal: strings.Join(refusals, "\n\n"),
+		Usage: models.Usage{
+			InputTokens:  r.Usage.InputTokens,
+			OutputTokens: r.Usage.OutputTokens,
+		},
 	}
-	contents := r.Output[1].Content
-	if len(contents) == 0 {
-		return "", fmt.Errorf("no content in Output[1]")
+
+	if r.Status == responseStatusIncomplete {
+		response.Incomplete = true
+		if r.IncompleteDetails != nil {
+			response.IncompleteReason = r.IncompleteDetails.Reason
+		}
+		return response, nil
 	}
-	if r.Status != "completed" {
-		return "", fmt.Errorf("OpenAI's response is not completed: status: %s, error: %s, details: %s", r.Status, r.Error, r.IncompleteDetails)
+
+	if response.Text == "" && response.Refusal == "" {
+		return models.Response{}, errors.New("OpenAI's response contains no output text")
 	}
 
-	return contents[0].Text, nil
+	return response, nil
 }
@@ -0,0 +1,12 @@
+package models
+
+import "context"
+
+// LLM is a model answering prompts, or a wrapper around one.
+type LLM interface {
+	Prompt(ctx context.Context, request Request) (Response, error)
+	// Continue asks the model to carry on with its last, incomplete, answer.
+	Continue(ctx context.Context) (Response, error)
+	GetModel() string
+	GetUser() string
+}
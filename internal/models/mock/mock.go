@@ -0,0 +1,238 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/spf13/viper"
+)
+
+// ProviderMock is the provider name reported by the simulated API errors.
+const ProviderMock = "mock"
+
+// noReplyAnswer is given when no scripted reply matches the prompt.
+const noReplyAnswer = "The mock script has no reply for this prompt."
+
+// Mock implements the LLM interface with answers read from a script file,
+// to use Gail without network access or API keys.
+type Mock struct {
+	// The model name reported by the mock.
+	Model models.Model
+	// Has no meaning or use. Done to satisfy interface implementation.
+	User string
+	// The scripted replies.
+	replies []Reply
+	// The index of the next reply to use among the replies without a match pattern.
+	nextOrdered int
+	// The reply given to the last prompt, used to continue an incomplete answer.
+	lastReply *Reply
+	// The logger used for logging messages.
+	Logger *slog.Logger
+}
+
+// ScriptConfig holds the content of a mock script file.
+type ScriptConfig struct {
+	Replies []Reply `mapstructure:"reply"`
+}
+
+// Reply is a scripted answer.
+type Reply struct {
+	// A regular expression matched against the prompt. Replies without one are used in order.
+	Match string `mapstructure:"match"`
+	// The answer text.
+	Answer string `mapstructure:"answer"`
+	// The time taken before answering (e.g. 2s). The models do not stream their answers,
+	// so a slowly generated answer is simulated with a latency.
+	Latency time.Duration `mapstructure:"latency"`
+	// A simulated API error: invalid_key, quota_exceeded, rate_limited, overloaded, context_too_long or server_error.
+	// Any other value is returned as a plain error.
+	Error string `mapstructure:"error"`
+	// A simulated refusal explanation.
+	Refusal string `mapstructure:"refusal"`
+	// A simulated incomplete answer reason (e.g. max_tokens).
+	Incomplete string `mapstructure:"incomplete"`
+	// The text given when continuing an incomplete answer.
+	Continuation string `mapstructure:"continuation"`
+
+	regex *regexp.Regexp
+}
+
+// New creates a Mock answering from the given script file.
+// A missing script file is an empty script: every prompt gets the same answer telling there is no reply.
+func New(logger *slog.Logger, scriptPath string, user string) (*Mock, error) {
+	mock := &Mock{
+		Model:  models.ModelMockName,
+		User:   user,
+		Logger: logger,
+	}
+
+	if _, err := os.Stat(scriptPath); errors.Is(err, os.ErrNotExist) {
+		logger.Info("Mock: no script file", slog.String("path", scriptPath))
+		return mock, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(scriptPath)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read the mock script '%s': %w", scriptPath, err)
+	}
+
+	var sc ScriptConfig
+	if err := v.Unmarshal(&sc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the mock script '%s': %w", scriptPath, err)
+	}
+
+	for i := range sc.Replies {
+		if sc.Replies[i].Match == "" {
+			continue
+		}
+		regex, err := regexp.Compile(sc.Replies[i].Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'match' pattern of reply #%d in the mock script '%s': %w", i+1, scriptPath, err)
+		}
+		sc.Replies[i].regex = regex
+	}
+
+	mock.replies = sc.Replies
+
+	return mock, nil
+}
+
+func (m *Mock) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
+	message := request.Message
+	reply := m.findReply(message)
+	m.lastReply = reply
+	if reply == nil {
+		return models.Response{Text: noReplyAnswer, Model: string(m.Model)}, nil
+	}
+
+	m.Logger.Info("Mock: replying", slog.String("match", reply.Match))
+
+	if err := sleep(ctx, reply.Latency); err != nil {
+		return models.Response{}, err
+	}
+
+	if reply.Error != "" {
+		return models.Response{}, simulatedError(reply.Error)
+	}
+
+	response := models.Response{
+		Text:             reply.Answer,
+		Refusal:          reply.Refusal,
+		Incomplete:       reply.Incomplete != "",
+		IncompleteReason: reply.Incomplete,
+		Usage:            usage(message, reply.Answer),
+		Model:            string(m.Model),
+	}
+
+	return response, nil
+}
+
+// Continue answers with the continuation of the last reply.
+func (m *Mock) Continue(ctx context.Context) (models.Response, error) {
+	if m.lastReply == nil || m.lastReply.Incomplete == "" {
+		return models.Response{}, errors.New("there is no incomplete mock answer to continue")
+	}
+
+	if err := sleep(ctx, m.lastReply.Latency); err != nil {
+		return models.Response{}, err
+	}
+
+	response := models.Response{
+		Text:  m.lastReply.Continuation,
+		Usage: usage("", m.lastReply.Continuation),
+		Model: string(m.Model),
+	}
+
+	return response, nil
+}
+
+// SetHistory does nothing as the scripted replies do not depend on the conversation.
+func (m *Mock) SetHistory(ctx context.Context, history []models.Message) error {
+	return nil
+}
+
+// GetModel returns the model name reported by the mock.
+func (m *Mock) GetModel() string {
+	return string(m.Model)
+}
+
+// GetUser returns the user used for the chat completion.
+func (m *Mock) GetUser() string {
+	return m.User
+}
+
+// findReply returns the first reply whose pattern matches the message or,
+// when none does, the next reply without a pattern.
+func (m *Mock) findReply(message string) *Reply {
+	for i := range m.replies {
+		if m.replies[i].regex != nil && m.replies[i].regex.MatchString(message) {
+			return &m.replies[i]
+		}
+	}
+
+	var ordered []*Reply
+	for i := range m.replies {
+		if m.replies[i].regex == nil {
+			ordered = append(ordered, &m.replies[i])
+		}
+	}
+	if len(ordered) == 0 {
+		return nil
+	}
+
+	reply := ordered[m.nextOrdered%len(ordered)]
+	m.nextOrdered++
+
+	return reply
+}
+
+// simulatedError returns the error matching the given name.
+func simulatedError(name string) error {
+	switch name {
+	case string(models.ErrorKindInvalidKey):
+		return models.NewAPIError(ProviderMock, http.StatusUnauthorized, "authentication_error", "", "simulated invalid API key")
+	case string(models.ErrorKindQuotaExceeded):
+		return models.NewAPIError(ProviderMock, http.StatusTooManyRequests, "insufficient_quota", "insufficient_quota", "simulated quota exceeded")
+	case string(models.ErrorKindRateLimited):
+		return models.NewAPIError(ProviderMock, http.StatusTooManyRequests, "rate_limit_error", "", "simulated rate limit")
+	case string(models.ErrorKindOverloaded):
+		return models.NewAPIError(ProviderMock, 529, "overloaded_error", "", "simulated overload")
+	case string(models.ErrorKindContextTooLong):
+		return models.NewAPIError(ProviderMock, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", "simulated context too long")
+	case string(models.ErrorKindServerError):
+		return models.NewAPIError(ProviderMock, http.StatusInternalServerError, "api_error", "", "simulated server error")
+	default:
+		return errors.New(name)
+	}
+}
+
+// usage estimates the tokens consumed, using the rule of thumb of ~4 characters per token.
+func usage(prompt string, answer string) models.Usage {
+	return models.Usage{
+		InputTokens:  len(prompt) / 4,
+		OutputTokens: len(answer) / 4,
+	}
+}
+
+// sleep waits for the given duration, unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
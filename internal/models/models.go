@@ -15,4 +15,8 @@ const (
 	ModelClaude          string = "claude"
 	ModelClaudeName      Model  = "claude-opus-4-20250514"
 	ModelClaudeMaxTokens Token  = 32000 // 32,000
+
+	ModelMock          string = "mock"
+	ModelMockName      Model  = "mock"
+	ModelMockMaxTokens Token  = 32000 // 32,000
 )
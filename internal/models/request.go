@@ -0,0 +1,59 @@
+package models
+
+import "github.com/nycruz/gail/internal/schema"
+
+// Request holds everything sent to a LLM model for a prompt.
+type Request struct {
+	// The name of the role the model answers as (e.g. Software Engineer).
+	RoleName string
+	// The persona of the role (e.g. You are a Software Engineer).
+	RolePersona string
+	// The ID of the selected skill.
+	SkillID string
+	// The instruction of the selected skill.
+	SkillInstruction string
+	// The user's message.
+	Message string
+	// The generation parameters declared by the role and skill.
+	Params Params
+	// The JSON Schema the answer must match, nil for a free form answer.
+	Schema *schema.Schema
+}
+
+// Params holds the generation parameters of a prompt.
+// Unset parameters are left to the model's defaults.
+type Params struct {
+	// The sampling temperature.
+	Temperature *float64 `mapstructure:"temperature"`
+	// The nucleus sampling probability mass.
+	TopP *float64 `mapstructure:"topP"`
+	// Sequences that stop the generation when produced.
+	Stop []string `mapstructure:"stop"`
+	// The maximum number of tokens to generate, overriding the model's default.
+	MaxTokens Token `mapstructure:"maxTokens"`
+}
+
+// Merge returns the parameters overridden by the ones set in other.
+func (p Params) Merge(other Params) Params {
+	if other.Temperature != nil {
+		p.Temperature = other.Temperature
+	}
+	if other.TopP != nil {
+		p.TopP = other.TopP
+	}
+	if len(other.Stop) > 0 {
+		p.Stop = other.Stop
+	}
+	if other.MaxTokens > 0 {
+		p.MaxTokens = other.MaxTokens
+	}
+	return p
+}
+
+// MaxTokensOrDefault returns the maximum number of tokens to generate, or the given default when unset.
+func (p Params) MaxTokensOrDefault(defaultMaxTokens Token) Token {
+	if p.MaxTokens > 0 {
+		return p.MaxTokens
+	}
+	return defaultMaxTokens
+}
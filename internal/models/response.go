@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrContinueNotSupported is returned by models that cannot continue an incomplete answer.
+var ErrContinueNotSupported = errors.New("continuing an incomplete answer is not supported by this model")
+
+// Response holds the answer of a LLM model to a prompt.
+type Response struct {
+	// The text of the answer.
+	Text string
+	// The explanation given by the model when it refuses to answer.
+	Refusal string
+	// Whether the model stopped before finishing its answer.
+	Incomplete bool
+	// Why the answer is incomplete (e.g. max_output_tokens).
+	IncompleteReason string
+	// The number of tokens consumed by the prompt and the answer.
+	Usage Usage
+	// The model that answered.
+	Model string
+	// The position in the fallback chain of the model that answered, 0 for the preferred model
+	// or when there is no fallback chain.
+	FallbackIndex int
+	// Whether the prompt was blocked by the validation rules, and answered by Gail instead of a model.
+	Blocked bool
+	// The values of the prompt replaced by placeholders before it was sent.
+	Substitutions []Substitution
+}
+
+// Substitution is a sensitive value replaced by a placeholder in a prompt.
+type Substitution struct {
+	// The placeholder sent to the model instead of the value (e.g. <EMAIL_1>).
+	Placeholder string
+	// The rule that matched the value.
+	Name string
+	// The original value, only kept locally.
+	Value string
+}
+
+// Usage holds the number of tokens consumed by a prompt.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+const (
+	RoleUser      string = "user"
+	RoleAssistant string = "assistant"
+)
+
+// Message is a turn of a conversation, as exchanged with any model.
+type Message struct {
+	Role    string
+	Content string
+}
+
+// HistorySetter is implemented by models able to pick up a conversation held with another model.
+type HistorySetter interface {
+	// SetHistory replaces the conversation history of the model.
+	SetHistory(ctx context.Context, history []Message) error
+}
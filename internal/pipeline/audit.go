@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nycruz/gail/internal/audit"
+	"github.com/nycruz/gail/internal/models"
+)
+
+// Audit records the prompts, continuations and handovers to the audit log before they are
+// sent, and records the requests that failed once more afterwards. It must come after the
+// Validate middleware, so that the requests are recorded once validated and redacted, and the
+// blocked prompts are recorded too. A request that cannot be recorded is not sent.
+func Audit(auditLog *audit.Log, provider string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (models.Response, error) {
+			var entry audit.Entry
+			switch call.Kind {
+			case KindPrompt:
+				action := audit.SentAction(call.Findings, call.Substitutions)
+				if call.Blocked {
+					action = audit.ActionBlocked
+				}
+				entry = audit.NewEntry(provider, call.Model, call.Request, call.Request.Message, call.Findings, action)
+			case KindContinue:
+				entry = audit.NewEntry(provider, call.Model, models.Request{}, "", nil, audit.ActionContinue)
+			case KindHandover:
+				contents := make([]string, 0, len(call.History))
+				for _, message := range call.History {
+					contents = append(contents, message.Content)
+				}
+				entry = audit.NewEntry(provider, call.Model, models.Request{}, strings.Join(contents, "\n"), nil, audit.ActionHandover)
+			default:
+				return next(ctx, call)
+			}
+
+			if err := auditLog.Record(entry); err != nil {
+				return models.Response{}, err
+			}
+
+			response, err := next(ctx, call)
+			if err != nil {
+				failed := entry
+				failed.Time = time.Now().UTC()
+				failed.Action = audit.ActionFailed
+				failed.Findings = nil
+				if recordErr := auditLog.Record(failed); recordErr != nil {
+					return models.Response{}, errors.Join(err, recordErr)
+				}
+			}
+
+			return response, err
+		}
+	}
+}
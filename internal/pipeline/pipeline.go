@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/validator"
+)
+
+// Interface Guards
+var _ models.LLM = (*Pipeline)(nil)
+var _ models.HistorySetter = (*Pipeline)(nil)
+
+// Kind is what a call asks the model to do.
+type Kind string
+
+const (
+	// KindPrompt sends the request to the model.
+	KindPrompt Kind = "prompt"
+	// KindContinue asks the model to carry on with its last answer.
+	KindContinue Kind = "continue"
+	// KindHandover gives the model the conversation held with another model.
+	KindHandover Kind = "handover"
+)
+
+// Call is a call to the model going through the pipeline. The middleware can rewrite it,
+// and share what they found with the middleware wrapping them.
+type Call struct {
+	Kind Kind
+	// The model the call is sent to.
+	Model string
+	// The request of a prompt.
+	Request models.Request
+	// The conversation of a handover.
+	History []models.Message
+	// The validation findings of the request.
+	Findings []validator.Finding
+	// The values replaced with placeholders in the request.
+	Substitutions []models.Substitution
+	// Whether the request was blocked by a middleware. It goes through the next middleware,
+	// but it is not sent to the model.
+	Blocked bool
+}
+
+// Handler sends the call to the model, or to the next middleware of the pipeline.
+// The response of a handover is empty.
+type Handler func(ctx context.Context, call *Call) (models.Response, error)
+
+// Middleware wraps the next handler of the pipeline. It can inspect or rewrite the call
+// before passing it on, block it by answering without calling next, and inspect or rewrite
+// the response on its way back.
+type Middleware func(next Handler) Handler
+
+// Pipeline implements the LLM interface by passing the calls through ordered middleware
+// before they reach the model.
+type Pipeline struct {
+	llm     models.LLM
+	handler Handler
+}
+
+// New wraps the model with the given middleware. The first middleware is the outermost one:
+// it sees the call first and the response last.
+func New(llm models.LLM, middleware ...Middleware) *Pipeline {
+	p := &Pipeline{llm: llm}
+
+	handler := p.send
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	p.handler = handler
+
+	return p
+}
+
+func (p *Pipeline) Prompt(ctx context.Context, request models.Request) (models.Response, error) {
+	return p.handler(ctx, &Call{Kind: KindPrompt, Model: p.llm.GetModel(), Request: request})
+}
+
+func (p *Pipeline) Continue(ctx context.Context) (models.Response, error) {
+	return p.handler(ctx, &Call{Kind: KindContinue, Model: p.llm.GetModel()})
+}
+
+// SetHistory hands the conversation over to the model, through the middleware.
+func (p *Pipeline) SetHistory(ctx context.Context, history []models.Message) error {
+	_, err := p.handler(ctx, &Call{Kind: KindHandover, Model: p.llm.GetModel(), History: history})
+	return err
+}
+
+func (p *Pipeline) GetModel() string {
+	return p.llm.GetModel()
+}
+
+func (p *Pipeline) GetUser() string {
+	return p.llm.GetUser()
+}
+
+// send is the innermost handler, sending the call to the model.
+func (p *Pipeline) send(ctx context.Context, call *Call) (models.Response, error) {
+	if call.Blocked {
+		return models.Response{Blocked: true}, nil
+	}
+
+	switch call.Kind {
+	case KindContinue:
+		return p.llm.Continue(ctx)
+	case KindHandover:
+		hs, ok := p.llm.(models.HistorySetter)
+		if !ok {
+			return models.Response{}, errors.New("the model cannot take over a conversation")
+		}
+		return models.Response{}, hs.SetHistory(ctx, call.History)
+	default:
+		return p.llm.Prompt(ctx, call.Request)
+	}
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/validator"
+)
+
+// Validate blocks the requests matching the validation rules with the block action, and
+// replaces the matches of the rules with the redact action with placeholders. The original
+// values are put back in the answers, and the conversations handed over are redacted too.
+func Validate(v *validator.Validator) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (models.Response, error) {
+			switch call.Kind {
+			case KindPrompt:
+				call.Findings = v.Validate(call.Request.Message)
+				if message, isBlocked := validator.BlockingMessage(call.Findings); isBlocked {
+					// The blocked call still goes through the next middleware, e.g. to be audited.
+					call.Blocked = true
+					if _, err := next(ctx, call); err != nil {
+						return models.Response{}, err
+					}
+					return models.Response{Text: message, Blocked: true}, nil
+				}
+				call.Request.Message, call.Substitutions = v.Redact(call.Request.Message)
+			case KindHandover:
+				history := make([]models.Message, 0, len(call.History))
+				for _, message := range call.History {
+					content, _ := v.Redact(message.Content)
+					history = append(history, models.Message{Role: message.Role, Content: content})
+				}
+				call.History = history
+			}
+
+			response, err := next(ctx, call)
+			if err != nil {
+				return models.Response{}, err
+			}
+
+			response.Text = v.Restore(response.Text)
+			if call.Kind == KindPrompt {
+				response.Substitutions = call.Substitutions
+			}
+
+			return response, nil
+		}
+	}
+}
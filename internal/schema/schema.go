@@ -0,0 +1,341 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema describing a structured answer.
+// Validation supports the subset of JSON Schema used to describe answers:
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum, maximum,
+// allOf, anyOf and oneOf.
+type Schema struct {
+	// The name of the schema, sent to the models that require one.
+	Name string
+	// The schema as JSON.
+	Raw json.RawMessage
+
+	root map[string]any
+}
+
+// Parse parses the given JSON Schema.
+func Parse(name string, raw string) (*Schema, error) {
+	var root map[string]any
+	if err := json.Unmarshal([]byte(raw), &root); err != nil {
+		return nil, fmt.Errorf("the JSON schema is not a valid JSON object: %w", err)
+	}
+
+	s := &Schema{
+		Name: name,
+		Raw:  json.RawMessage(raw),
+		root: root,
+	}
+
+	return s, nil
+}
+
+// Map returns the schema as a map, to embed it in a request body.
+func (s *Schema) Map() map[string]any {
+	return s.root
+}
+
+// Validate checks that the given JSON document matches the schema.
+// All the mismatches found are returned, joined.
+func (s *Schema) Validate(document []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("the answer is not valid JSON: %w", err)
+	}
+
+	var errs []error
+	validate(s.root, value, "$", &errs)
+
+	return errors.Join(errs...)
+}
+
+// Indent returns the JSON document pretty printed.
+func Indent(document []byte) (string, error) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, bytes.TrimSpace(document), "", "  "); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func validate(schema map[string]any, value any, path string, errs *[]error) {
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesAnyType(value, types) {
+		*errs = append(*errs, fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), typeOf(value)))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !containsValue(enum, value) {
+		*errs = append(*errs, fmt.Errorf("%s: value is not one of the allowed values", path))
+	}
+
+	if constant, ok := schema["const"]; ok && !equalValues(constant, value) {
+		*errs = append(*errs, fmt.Errorf("%s: value does not match the expected constant", path))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		validateObject(schema, v, path, errs)
+	case []any:
+		validateArray(schema, v, path, errs)
+	case string:
+		validateString(schema, v, path, errs)
+	case json.Number:
+		validateNumber(schema, v, path, errs)
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]any); ok {
+				validate(subSchema, value, path, errs)
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok && countMatches(anyOf, value, path) == 0 {
+		*errs = append(*errs, fmt.Errorf("%s: value matches none of the 'anyOf' schemas", path))
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok && countMatches(oneOf, value, path) != 1 {
+		*errs = append(*errs, fmt.Errorf("%s: value must match exactly one of the 'oneOf' schemas", path))
+	}
+}
+
+func validateObject(schema map[string]any, object map[string]any, path string, errs *[]error) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, fmt.Errorf("%s: missing required property '%s'", path, name))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := fmt.Sprintf("%s.%s", path, name)
+		if propertySchema, ok := properties[name].(map[string]any); ok {
+			validate(propertySchema, object[name], propertyPath, errs)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, fmt.Errorf("%s: property is not allowed", propertyPath))
+			}
+		case map[string]any:
+			validate(additional, object[name], propertyPath, errs)
+		}
+	}
+}
+
+func validateArray(schema map[string]any, array []any, path string, errs *[]error) {
+	if minItems, ok := number(schema["minItems"]); ok && float64(len(array)) < minItems {
+		*errs = append(*errs, fmt.Errorf("%s: expected at least %v items, got %d", path, minItems, len(array)))
+	}
+	if maxItems, ok := number(schema["maxItems"]); ok && float64(len(array)) > maxItems {
+		*errs = append(*errs, fmt.Errorf("%s: expected at most %v items, got %d", path, maxItems, len(array)))
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range array {
+			validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateString(schema map[string]any, s string, path string, errs *[]error) {
+	length := float64(utf8.RuneCountInString(s))
+	if minLength, ok := number(schema["minLength"]); ok && length < minLength {
+		*errs = append(*errs, fmt.Errorf("%s: expected at least %v characters", path, minLength))
+	}
+	if maxLength, ok := number(schema["maxLength"]); ok && length > maxLength {
+		*errs = append(*errs, fmt.Errorf("%s: expected at most %v characters", path, maxLength))
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: invalid pattern in the schema: %w", path, err))
+		} else if !regex.MatchString(s) {
+			*errs = append(*errs, fmt.Errorf("%s: value does not match the pattern '%s'", path, pattern))
+		}
+	}
+}
+
+func validateNumber(schema map[string]any, n json.Number, path string, errs *[]error) {
+	f, err := n.Float64()
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: invalid number: %w", path, err))
+		return
+	}
+
+	if minimum, ok := number(schema["minimum"]); ok && f < minimum {
+		*errs = append(*errs, fmt.Errorf("%s: expected a value of at least %v", path, minimum))
+	}
+	if maximum, ok := number(schema["maximum"]); ok && f > maximum {
+		*errs = append(*errs, fmt.Errorf("%s: expected a value of at most %v", path, maximum))
+	}
+}
+
+// countMatches returns the number of schemas the value matches.
+func countMatches(schemas []any, value any, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		subSchema, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+		var subErrs []error
+		validate(subSchema, value, path, &subErrs)
+		if len(subErrs) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// schemaTypes returns the types allowed by the 'type' keyword.
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchesAnyType(value any, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// number returns the value of a numeric schema keyword.
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares a value of the schema with a value of the document,
+// whose numbers are decoded as json.Number.
+func equalValues(schemaValue any, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		sf, isNumber := schemaValue.(float64)
+		return err == nil && isNumber && f == sf
+	}
+	return reflect.DeepEqual(schemaValue, normalize(value))
+}
+
+// normalize converts the json.Number of a document value into float64, like the schema values.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []any:
+		normalized := make([]any, len(v))
+		for i, e := range v {
+			normalized[i] = normalize(e)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for k, e := range v {
+			normalized[k] = normalize(e)
+		}
+		return normalized
+	}
+	return value
+}
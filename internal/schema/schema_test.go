@@ -0,0 +1,192 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+// findingsSchema is the schema of the code-review-findings skill.
+const findingsSchema = `{
+  "type": "object",
+  "properties": {
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "severity": { "type": "string", "enum": ["low", "medium", "high"] },
+          "line": { "type": "integer", "minimum": 1 },
+          "description": { "type": "string", "minLength": 1 }
+        },
+        "required": ["severity", "description"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["findings"],
+  "additionalProperties": false
+}`
+
+func TestParse(t *testing.T) {
+	s, err := Parse("findings", findingsSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "findings" || s.Map()["type"] != "object" {
+		t.Errorf("Parse() = %+v, want the findings schema", s)
+	}
+
+	for _, raw := range []string{"", "not json", `["an", "array"]`} {
+		if _, err := Parse("invalid", raw); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		// The parts of the error message, none when the document is valid.
+		wantErrs []string
+	}{
+		{
+			name:     "valid findings",
+			schema:   findingsSchema,
+			document: `{"findings": [{"severity": "high", "line": 3, "description": "The error is ignored."}]}`,
+		},
+		{
+			name:     "no findings",
+			schema:   findingsSchema,
+			document: `{"findings": []}`,
+		},
+		{
+			name:     "not json",
+			schema:   findingsSchema,
+			document: "Here are my findings:",
+			wantErrs: []string{"not valid JSON"},
+		},
+		{
+			name:     "missing required property",
+			schema:   findingsSchema,
+			document: `{}`,
+			wantErrs: []string{"$: missing required property 'findings'"},
+		},
+		{
+			name:     "all the mismatches",
+			schema:   findingsSchema,
+			document: `{"findings": [{"severity": "critical", "line": 1.5, "description": "", "fix": "x"}], "summary": "ok"}`,
+			wantErrs: []string{
+				"$.findings[0].severity: value is not one of the allowed values",
+				"$.findings[0].line: expected integer, got number",
+				"$.findings[0].description: expected at least 1 characters",
+				"$.findings[0].fix: property is not allowed",
+				"$.summary: property is not allowed",
+			},
+		},
+		{
+			name:     "minimum",
+			schema:   findingsSchema,
+			document: `{"findings": [{"severity": "low", "line": 0, "description": "Off by one."}]}`,
+			wantErrs: []string{"$.findings[0].line: expected a value of at least 1"},
+		},
+		{
+			name:     "type list",
+			schema:   `{"type": ["string", "null"]}`,
+			document: `null`,
+		},
+		{
+			name:     "wrong type",
+			schema:   `{"type": "array", "items": {"type": "string"}}`,
+			document: `{"a": 1}`,
+			wantErrs: []string{"$: expected array, got object"},
+		},
+		{
+			name:     "array bounds",
+			schema:   `{"type": "array", "minItems": 2, "maxItems": 3}`,
+			document: `[1]`,
+			wantErrs: []string{"$: expected at least 2 items, got 1"},
+		},
+		{
+			name:     "string length in characters",
+			schema:   `{"type": "string", "maxLength": 3}`,
+			document: `"été"`,
+		},
+		{
+			name:     "pattern",
+			schema:   `{"type": "string", "pattern": "^[A-Z]+-[0-9]+$"}`,
+			document: `"gail-12"`,
+			wantErrs: []string{"does not match the pattern"},
+		},
+		{
+			name:     "const",
+			schema:   `{"const": {"version": 1}}`,
+			document: `{"version": 1}`,
+		},
+		{
+			name:     "additional properties schema",
+			schema:   `{"type": "object", "additionalProperties": {"type": "number"}}`,
+			document: `{"a": 1, "b": "two"}`,
+			wantErrs: []string{"$.b: expected number, got string"},
+		},
+		{
+			name:     "anyOf",
+			schema:   `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`,
+			document: `true`,
+			wantErrs: []string{"$: value matches none of the 'anyOf' schemas"},
+		},
+		{
+			name:     "oneOf matching several",
+			schema:   `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`,
+			document: `2`,
+			wantErrs: []string{"$: value must match exactly one of the 'oneOf' schemas"},
+		},
+		{
+			name:     "allOf",
+			schema:   `{"allOf": [{"type": "number"}, {"maximum": 10}]}`,
+			document: `11`,
+			wantErrs: []string{"$: expected a value of at most 10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.name, tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Validate([]byte(tt.document))
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() succeeded, want the errors %q", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	got, err := Indent([]byte(" {\"findings\":[{\"line\":3}]}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"findings\": [\n    {\n      \"line\": 3\n    }\n  ]\n}"
+	if got != want {
+		t.Errorf("Indent() = %q, want %q", got, want)
+	}
+
+	if _, err := Indent([]byte("not json")); err == nil {
+		t.Error("Indent() succeeded on text that is not JSON, want an error")
+	}
+}
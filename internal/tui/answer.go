@@ -12,34 +12,116 @@ import (
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/validator"
 )
 
 type Answer struct {
-	msg    string
-	Answer string // Answer from Gail
-	Error  errMsg // Error from Gail
+	msg              string
+	Answer           string                // Answer from Gail
+	Error            errMsg                // Error from Gail
+	raw              string                // Answer before syntax highlighting
+	refusal          string                // Explanation given by the model when refusing to answer
+	incompleteReason string                // Why the answer was cut off, if it was
+	isIncomplete     bool                  // Whether the answer was cut off
+	isContinuation   bool                  // Whether the answer continues the previous one
+	model            string                // Model that answered
+	fallbackIndex    int                   // Position in the fallback chain of the model that answered, 0 for the selected one
+	substitutions    []models.Substitution // Values of the prompt replaced by placeholders before sending it
+	flags            []validator.Finding   // Matches of the response checks in the answer
 }
 
-func (m model) fetchAnswer(roleName string, rolePersona string, skillInstruction string, message string) tea.Cmd {
+func (m model) fetchAnswer(request models.Request) tea.Cmd {
 	ctx := context.Background()
 
 	return func() tea.Msg {
-		answer, err := m.llm.Prompt(ctx, roleName, rolePersona, skillInstruction, message)
-		m.logger.Info(fmt.Sprintf("LLM Answer: %v", answer))
+		response, err := prompt(ctx, m.llm, request)
+		m.logger.Info(fmt.Sprintf("LLM Answer: %v", response.Text))
 		if err != nil {
 			e := fmt.Errorf("%s: %w", m.llm.GetModel(), err)
 			return Answer{Error: e}
 		}
 
-		highlightedAnswer, err := highlightCodeSnippetsAndAssembleResponse(answer)
+		answer := m.flagAnswer(assembleAnswer(response, "", fmt.Sprintf("Answered as a %s!", request.RoleName)))
+		if answer.Error == nil && m.isFallbackAnswer(answer) {
+			answer.msg = fmt.Sprintf("Answered as a %s by %s, as %s failed!", request.RoleName, answer.model, m.llm.GetModel())
+		}
+		return answer
+	}
+}
+
+// newRequest builds the request sending the message with the current role and skill.
+func (m model) newRequest(message string) models.Request {
+	return models.Request{
+		RoleName:         m.role.Name,
+		RolePersona:      m.role.Persona,
+		SkillID:          m.skill.ID,
+		SkillInstruction: m.skill.Instruction,
+		Message:          message,
+		Params:           m.assistant.Params(m.role, m.skill),
+		Schema:           m.skill.JSONSchema,
+	}
+}
+
+// continueAnswer asks the LLM to carry on with its incomplete answer, which is given as previous.
+func (m model) continueAnswer(previous string) tea.Cmd {
+	ctx := context.Background()
+
+	return func() tea.Msg {
+		response, err := m.llm.Continue(ctx)
+		m.logger.Info(fmt.Sprintf("LLM Answer continuation: %v", response.Text))
 		if err != nil {
-			return Answer{Error: err}
+			e := fmt.Errorf("%s: %w", m.llm.GetModel(), err)
+			return Answer{Error: e, isContinuation: true}
 		}
 
-		return Answer{Answer: highlightedAnswer, msg: fmt.Sprintf("Answered as a %s!", roleName)}
+		answer := m.flagAnswer(assembleAnswer(response, previous, "Answer continued!"))
+		answer.isContinuation = true
+		return answer
 	}
 }
 
+// assembleAnswer appends the response text to previous and highlights the code snippets of the result.
+func assembleAnswer(response models.Response, previous string, msg string) Answer {
+	raw := previous + response.Text
+
+	highlightedAnswer, err := highlightCodeSnippetsAndAssembleResponse(raw)
+	if err != nil {
+		return Answer{Error: err}
+	}
+
+	if response.Refusal != "" {
+		msg = "The model refused to answer."
+	}
+
+	return Answer{
+		Answer:           highlightedAnswer,
+		msg:              msg,
+		raw:              raw,
+		refusal:          response.Refusal,
+		incompleteReason: response.IncompleteReason,
+		isIncomplete:     response.Incomplete,
+		model:            response.Model,
+		fallbackIndex:    response.FallbackIndex,
+		substitutions:    response.Substitutions,
+	}
+}
+
+// substitutionsNotice lists the values of the prompt that were replaced by placeholders.
+func substitutionsNotice(substitutions []models.Substitution) string {
+	lines := make([]string, 0, len(substitutions))
+	for _, s := range substitutions {
+		lines = append(lines, fmt.Sprintf("  %s = %s (%s)", s.Placeholder, s.Value, s.Name))
+	}
+	return fmt.Sprintf("[redacted before sending:\n%s]", strings.Join(lines, "\n"))
+}
+
+// isFallbackAnswer reports whether the answer was given by another model of the fallback chain than the one selected.
+// The model names cannot tell, as the APIs can report another name than the configured one (e.g. a dated version).
+func (m model) isFallbackAnswer(answer Answer) bool {
+	return answer.fallbackIndex > 0
+}
+
 // highlightCodeSnippetsAndAssembleResponse highlights all code snippets in the response
 func highlightCodeSnippetsAndAssembleResponse(response string) (string, error) {
 	snippets := extractCodeSnippets(response)
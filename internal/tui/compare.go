@@ -0,0 +1,278 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/nycruz/gail/internal/models"
+)
+
+// compareColumnSeparator separates the columns of the compare mode.
+const compareColumnSeparator = " │ "
+
+// compareColumn holds the conversation of one of the models being compared.
+type compareColumn struct {
+	llm       models.LLM
+	viewport  viewport.Model
+	messages  []string      // Messages to display in the column, before word wrapping
+	latency   time.Duration // Time taken by the last answer
+	usage     models.Usage  // Tokens consumed by the last answer
+	isLoading bool          // Whether the column is waiting for an answer
+}
+
+// compareAnswer is the answer of one of the compared models.
+type compareAnswer struct {
+	index   int
+	answer  Answer
+	latency time.Duration
+	usage   models.Usage
+}
+
+type CompareItem struct {
+	index   int
+	model   string
+	summary string
+}
+
+// implement the list.Item interface
+func (i CompareItem) Title() string {
+	return i.model
+}
+
+// implement the list.Item interface
+func (i CompareItem) Description() string {
+	return i.summary
+}
+
+// implement the list.Item interface
+func (i CompareItem) FilterValue() string {
+	return i.model
+}
+
+// setupCompareColumns creates one column per model to compare.
+func setupCompareColumns(llms []models.LLM) []compareColumn {
+	columns := make([]compareColumn, 0, len(llms))
+	for _, llm := range llms {
+		columns = append(columns, compareColumn{
+			llm:      llm,
+			viewport: viewport.New(0, 0),
+		})
+	}
+	return columns
+}
+
+// setupCompareList creates a list.Model of the compared models for the user to pick the best answer from.
+func setupCompareList() list.Model {
+	cl := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
+	cl.Title = "Continue the conversation with"
+	cl.SetShowHelp(true)
+	cl.SetFilteringEnabled(false)
+
+	return cl
+}
+
+// isCompareMode reports whether prompts are sent to several models side by side.
+func (m model) isCompareMode() bool {
+	return len(m.compareColumns) > 0
+}
+
+// isComparing reports whether at least one of the compared models is still answering.
+func (m model) isComparing() bool {
+	for _, column := range m.compareColumns {
+		if column.isLoading {
+			return true
+		}
+	}
+	return false
+}
+
+// ownCompareColumns copies the compared columns before they are changed, as the earlier
+// copies of the model share their backing array.
+func (m *model) ownCompareColumns() {
+	m.compareColumns = slices.Clone(m.compareColumns)
+}
+
+// fetchCompareAnswers sends the message to all the compared models in parallel.
+func (m model) fetchCompareAnswers(request models.Request) (model, tea.Cmd) {
+	m.ownCompareColumns()
+	cmds := make([]tea.Cmd, 0, len(m.compareColumns))
+	for i := range m.compareColumns {
+		m.compareColumns[i].isLoading = true
+		cmds = append(cmds, m.fetchCompareAnswer(i, request))
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m model) fetchCompareAnswer(index int, request models.Request) tea.Cmd {
+	ctx := context.Background()
+	llm := m.compareColumns[index].llm
+
+	return func() tea.Msg {
+		start := time.Now()
+		response, err := prompt(ctx, llm, request)
+		latency := time.Since(start)
+		if err != nil {
+			e := fmt.Errorf("%s: %w", llm.GetModel(), err)
+			return compareAnswer{index: index, answer: Answer{Error: e}, latency: latency}
+		}
+
+		return compareAnswer{
+			index:   index,
+			answer:  m.flagAnswer(assembleAnswer(response, "", "")),
+			latency: latency,
+			usage:   response.Usage,
+		}
+	}
+}
+
+// handleCompareAnswer adds the answer of a compared model to its column.
+func (m model) handleCompareAnswer(msg compareAnswer) (model, tea.Cmd) {
+	m.ownCompareColumns()
+	column := &m.compareColumns[msg.index]
+	column.isLoading = false
+	column.latency = msg.latency
+	column.usage = msg.usage
+
+	gailPrompt := m.receiverStyle.Render("\nGail: ")
+	if msg.answer.Error != nil {
+		m.logger.Error("LLM prompt failed", "model", column.llm.GetModel(), "error", msg.answer.Error.Error())
+		errorMessage, _ := describeError(msg.answer.Error)
+		gailPrompt += warningStyle.Render(errorMessage)
+	} else {
+		gailPrompt += msg.answer.Answer
+		if len(msg.answer.substitutions) > 0 {
+			gailPrompt += "\n" + warningStyle.Render(substitutionsNotice(msg.answer.substitutions))
+		}
+		if len(msg.answer.flags) > 0 {
+			gailPrompt += "\n" + warningStyle.Render(flagsNotice(msg.answer.raw, msg.answer.flags))
+		}
+		if msg.answer.refusal != "" {
+			gailPrompt += "\n" + refusalStyle.Render("Refused: "+msg.answer.refusal)
+		}
+		if msg.answer.isIncomplete {
+			gailPrompt += "\n" + warningStyle.Render(fmt.Sprintf("[answer incomplete: %s]", incompleteReasonOrDefault(msg.answer.incompleteReason)))
+		}
+	}
+
+	column.messages = append(slices.Clip(column.messages), m.senderStyle.Render("You: ")+m.textAreaContent, gailPrompt+"\n")
+	column.viewport.SetContent(wordwrap.String(strings.Join(column.messages, "\n"), column.viewport.Width))
+	column.viewport.GotoBottom()
+
+	if m.isComparing() {
+		return m, nil
+	}
+
+	m.isLoading = false
+	m.statusBarMessage = fmt.Sprintf("Compared %d models. Press 'ctrl+b' to continue the conversation with the best one.", len(m.compareColumns))
+
+	return m, m.saveConversation(removeANSICodes(m.compareTranscript()))
+}
+
+// compareTranscript returns the conversations of all the compared models, one after another.
+// It starts with the first prompt so the saved file is named after it.
+func (m model) compareTranscript() string {
+	var b strings.Builder
+	if len(m.compareColumns[0].messages) > 0 {
+		b.WriteString(m.compareColumns[0].messages[0])
+		b.WriteString("\n\n")
+	}
+	for _, column := range m.compareColumns {
+		fmt.Fprintf(&b, "## %s\n\n", column.llm.GetModel())
+		b.WriteString(strings.Join(column.messages, "\n"))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// compareItems lists the compared models with the latency and token usage of their last answer.
+func (m model) compareItems() []list.Item {
+	items := make([]list.Item, 0, len(m.compareColumns))
+	for i, column := range m.compareColumns {
+		items = append(items, CompareItem{
+			index:   i,
+			model:   column.llm.GetModel(),
+			summary: column.summary(),
+		})
+	}
+	return items
+}
+
+// selectCompareColumn leaves the compare mode and continues the conversation with the model of the given column.
+func (m model) selectCompareColumn(index int) model {
+	column := m.compareColumns[index]
+
+	m.llm = column.llm
+	m.messagesDisplay = []string{}
+	for _, message := range column.messages {
+		m.messagesDisplay = append(m.messagesDisplay, wordwrap.String(message, m.viewportCurrentWidth-ReducerWidthForBorder))
+	}
+	m.viewport.SetContent(strings.Join(m.messagesDisplay, "\n"))
+	m.viewport.GotoBottom()
+
+	m.compareColumns = nil
+	m.statusBarMessage = fmt.Sprintf("Continuing the conversation with %s.", column.llm.GetModel())
+
+	return m
+}
+
+// resizeCompareColumns splits the viewport width between the compared models.
+func (m model) resizeCompareColumns() model {
+	numColumns := len(m.compareColumns)
+	if numColumns == 0 {
+		return m
+	}
+	m.ownCompareColumns()
+
+	separatorsWidth := lipgloss.Width(compareColumnSeparator) * (numColumns - 1)
+	columnWidth := getMax(0, (m.viewport.Width-separatorsWidth)/numColumns)
+	// One line is used by the column header.
+	columnHeight := getMax(0, m.viewport.Height-1)
+
+	for i := range m.compareColumns {
+		column := &m.compareColumns[i]
+		column.viewport.Width = columnWidth
+		column.viewport.Height = columnHeight
+		column.viewport.SetContent(wordwrap.String(strings.Join(column.messages, "\n"), columnWidth))
+	}
+	return m
+}
+
+// compareView renders the compared models side by side.
+func (m model) compareView() string {
+	separator := fadedStyle.Render(strings.TrimSuffix(strings.Repeat(compareColumnSeparator+"\n", m.viewport.Height), "\n"))
+
+	views := []string{}
+	for i, column := range m.compareColumns {
+		if i > 0 {
+			views = append(views, separator)
+		}
+
+		header := titleStyle.Render(truncate.StringWithTail(fmt.Sprintf("%s %s", column.llm.GetModel(), column.summary()), uint(column.viewport.Width), "…"))
+		views = append(views, lipgloss.NewStyle().Width(column.viewport.Width).Render(
+			lipgloss.JoinVertical(lipgloss.Left, header, column.viewport.View()),
+		))
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, views...)
+}
+
+// summary describes the latency and token usage of the last answer of the column.
+func (c compareColumn) summary() string {
+	if c.isLoading {
+		return "(answering...)"
+	}
+	if c.latency == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%s, %d in / %d out tokens)", c.latency.Round(100*time.Millisecond), c.usage.InputTokens, c.usage.OutputTokens)
+}
@@ -0,0 +1,54 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/nycruz/gail/internal/models"
+)
+
+// describeError turns an error returned by the LLM into an actionable message for the status bar.
+// It also reports whether sending the same prompt again is worth offering to the user.
+func describeError(err error) (string, bool) {
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) {
+		// Network failures and timeouts are usually transient, unlike the other errors,
+		// such as an answer that cannot be decoded.
+		var netErr net.Error
+		retryable := errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+		return fmt.Sprintf("Error fetching answer: %v", err), retryable
+	}
+
+	var msg string
+	switch apiErr.Kind {
+	case models.ErrorKindInvalidKey:
+		msg = fmt.Sprintf("The %s API key was rejected. Check the API key environment variable and restart Gail.", apiErr.Provider)
+	case models.ErrorKindQuotaExceeded:
+		msg = fmt.Sprintf("Your %s quota or credit balance is exhausted. Check your plan and billing details.", apiErr.Provider)
+	case models.ErrorKindRateLimited:
+		msg = fmt.Sprintf("Too many requests were sent to %s. Wait a moment before retrying.", apiErr.Provider)
+	case models.ErrorKindOverloaded:
+		msg = fmt.Sprintf("%s is overloaded right now. Retry in a few seconds.", apiErr.Provider)
+	case models.ErrorKindContextTooLong:
+		msg = "The conversation is too long for the model's context window. Shorten your prompt or restart Gail to start a new conversation."
+	case models.ErrorKindPermissionError:
+		msg = fmt.Sprintf("Your %s API key is not allowed to use this model or endpoint.", apiErr.Provider)
+	case models.ErrorKindNotFound:
+		msg = fmt.Sprintf("%s could not find the requested model or resource.", apiErr.Provider)
+	case models.ErrorKindServerError:
+		msg = fmt.Sprintf("%s returned a server error (status %d).", apiErr.Provider, apiErr.StatusCode)
+		if apiErr.StatusCode == 0 {
+			msg = fmt.Sprintf("%s returned a server error: %s", apiErr.Provider, apiErr.Message)
+		}
+	default:
+		msg = fmt.Sprintf("Error fetching answer: %s", apiErr.Message)
+	}
+
+	if apiErr.RequestID != "" {
+		msg = fmt.Sprintf("%s (request id: %s)", msg, apiErr.RequestID)
+	}
+
+	return msg, apiErr.Retryable
+}
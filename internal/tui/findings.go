@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/nycruz/gail/internal/validator"
+)
+
+// validatedInput holds the findings of the validation of the textarea content.
+type validatedInput struct {
+	findings []validator.Finding
+}
+
+// checkAndSend validates the textarea content before sending it. The validation runs the
+// hooks and the moderation check, which can take seconds, so it runs out of the Update loop,
+// with the textarea blurred so that the input stays the one validated.
+func (m model) checkAndSend() (model, tea.Cmd) {
+	if m.isLoading {
+		return m, nil
+	}
+
+	content := m.textarea.Value()
+	m.isLoading = true
+	m.isValidating = true
+	m.textarea.Blur()
+
+	validate := func() tea.Msg {
+		return validatedInput{findings: m.validator.Validate(content)}
+	}
+	return m, tea.Batch(m.spinner.Tick, validate)
+}
+
+// handleValidatedInput sends the validated input. Inputs with blocking findings are kept in
+// the textarea to be edited, and inputs with warnings wait for the user to confirm.
+func (m model) handleValidatedInput(msg validatedInput) (model, tea.Cmd) {
+	m.isLoading = false
+	m.isValidating = false
+
+	if message, isBlocked := validator.BlockingMessage(msg.findings); isBlocked {
+		m.statusBarMessage = strings.ReplaceAll(message, "\n", "")
+		return m, tea.Batch(m.textarea.Focus(), clearStatusBarAfter(clearStatusBarAfterSeconds*time.Second))
+	}
+
+	if warnings := validator.Filter(msg.findings, validator.ActionWarn); len(warnings) > 0 {
+		m.isWarnConfirmPrompt = true
+		m.warnFindings = warnings
+		m.statusBarMessage = fmt.Sprintf("Your input may contain sensitive information (%d findings). Press 'enter' to send anyway, 'a' to allow these values for the session, 'esc' to edit.", len(warnings))
+		return m, nil
+	}
+
+	return m.send()
+}
+
+// send sends the textarea content to the model(s).
+func (m model) send() (model, tea.Cmd) {
+	m.textAreaContent = m.textarea.Value()
+	m.textarea.Reset()
+	m.textarea.Blur()
+	m.focusOnTextArea = false
+	m.isLoading = true
+	m.canContinue = false
+	m.continueRounds = 0
+	if m.isCompareMode() {
+		m.retryCmd = nil
+		m, cmd := m.fetchCompareAnswers(m.newRequest(m.textAreaContent))
+		return m, tea.Batch(m.spinner.Tick, cmd)
+	}
+	m.retryCmd = m.fetchAnswer(m.newRequest(m.textAreaContent))
+	return m, tea.Batch(m.spinner.Tick, m.retryCmd)
+}
+
+// confirmWarnings sends the input the user confirmed despite the warnings, or goes back to editing it.
+func (m model) confirmWarnings(confirmed bool) (model, tea.Cmd) {
+	m.isWarnConfirmPrompt = false
+	m.warnFindings = nil
+	m.statusBarMessage = m.defaultStatus()
+
+	if confirmed {
+		return m.send()
+	}
+
+	m.focusOnTextArea = true
+	return m, m.textarea.Focus()
+}
+
+// warnConfirmView shows the input with the spans of the warnings highlighted, followed by the list of warnings.
+func (m model) warnConfirmView() string {
+	content := m.textarea.Value()
+
+	var b strings.Builder
+	last := 0
+	for _, finding := range m.warnFindings {
+		// Overlapping findings are only highlighted once.
+		if finding.Start < last {
+			continue
+		}
+		b.WriteString(content[last:finding.Start])
+		b.WriteString(findingStyle(finding.Severity).Render(content[finding.Start:finding.End]))
+		last = finding.End
+	}
+	b.WriteString(content[last:])
+
+	b.WriteString("\n\n")
+	for _, finding := range m.warnFindings {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("[%s] %s", finding.Severity, finding.Name)))
+		b.WriteString("\n")
+	}
+	b.WriteString(fadedStyle.Render("Press 'enter' to send anyway, 'a' to allow these values for the session and send, 'esc' to edit."))
+
+	return wordwrap.String(b.String(), m.textAreaCurrentWidth-ReducerWidthForBorder)
+}
+
+// findingStyle returns the style highlighting a finding of the given severity.
+func findingStyle(severity validator.Severity) lipgloss.Style {
+	switch severity {
+	case validator.SeverityLow:
+		return lowFindingStyle
+	case validator.SeverityMedium:
+		return mediumFindingStyle
+	default:
+		return highFindingStyle
+	}
+}
+
+// flagBadge is put in front of the lines of the answers flagged by the response checks.
+const flagBadge = "⚠ "
+
+// flagAnswer runs the response checks on the answer, and puts a warning badge in front of its flagged lines.
+func (m model) flagAnswer(answer Answer) Answer {
+	if answer.Error != nil {
+		return answer
+	}
+
+	answer.flags = m.validator.CheckResponse(answer.raw)
+	if len(answer.flags) == 0 {
+		return answer
+	}
+
+	// The syntax highlighting keeps the lines of the answer, unless a lexer added one.
+	lines := strings.Split(answer.Answer, "\n")
+	if len(lines) != strings.Count(answer.raw, "\n")+1 {
+		return answer
+	}
+	for line, findings := range flaggedLines(answer.raw, answer.flags) {
+		lines[line] = findingStyle(maxSeverity(findings)).Render(flagBadge) + lines[line]
+	}
+	answer.Answer = strings.Join(lines, "\n")
+
+	return answer
+}
+
+// flaggedLines returns the findings of the response checks by the index of the line of the answer they start on.
+func flaggedLines(raw string, flags []validator.Finding) map[int][]validator.Finding {
+	lines := make(map[int][]validator.Finding)
+	for _, flag := range flags {
+		line := strings.Count(raw[:flag.Start], "\n")
+		lines[line] = append(lines[line], flag)
+	}
+	return lines
+}
+
+// flagsNotice lists the lines of the answer flagged by the response checks.
+func flagsNotice(raw string, flags []validator.Finding) string {
+	lines := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		line := strings.Count(raw[:flag.Start], "\n") + 1
+		lines = append(lines, fmt.Sprintf("  line %d: %s (%s)", line, flag.Name, flag.Severity))
+	}
+	return fmt.Sprintf("[%sflagged by the response checks:\n%s]", flagBadge, strings.Join(lines, "\n"))
+}
+
+// maxSeverity returns the highest severity of the findings.
+func maxSeverity(findings []validator.Finding) validator.Severity {
+	severity := validator.SeverityLow
+	for _, finding := range findings {
+		switch {
+		case finding.Severity == validator.SeverityHigh:
+			return validator.SeverityHigh
+		case finding.Severity == validator.SeverityMedium:
+			severity = validator.SeverityMedium
+		}
+	}
+	return severity
+}
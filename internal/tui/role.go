@@ -4,6 +4,7 @@ type RoleItem struct {
 	id      string
 	name    string
 	persona string
+	source  string
 }
 
 // implement the list.Item interface
@@ -18,7 +19,7 @@ func (i RoleItem) Title() string {
 
 // implement the list.Item interface
 func (i RoleItem) Description() string {
-	return ""
+	return i.source
 }
 
 func (i RoleItem) ID() string {
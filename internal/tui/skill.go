@@ -1,9 +1,12 @@
 package tui
 
+import "fmt"
+
 type SkillItem struct {
 	id          string
 	instruction string
 	description string
+	source      string
 }
 
 // implement the list.Item interface
@@ -13,7 +16,10 @@ func (i SkillItem) Title() string {
 
 // implement the list.Item interface
 func (i SkillItem) Description() string {
-	return i.description
+	if i.description == "" {
+		return i.source
+	}
+	return fmt.Sprintf("%s (%s)", i.description, i.source)
 }
 
 // implement the list.Item interface
@@ -0,0 +1,54 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/schema"
+)
+
+// prompt sends the request to the LLM. When the request has a JSON schema, the
+// answer is checked against it and the LLM is asked once to fix a mismatching answer.
+func prompt(ctx context.Context, llm models.LLM, request models.Request) (models.Response, error) {
+	response, err := llm.Prompt(ctx, request)
+	if err != nil || request.Schema == nil || response.Refusal != "" || response.Incomplete {
+		return response, err
+	}
+	// Messages rejected by the input validation are answered by Gail, not a model, and shown as is.
+	if response.Blocked {
+		return response, nil
+	}
+
+	validationErr := request.Schema.Validate([]byte(response.Text))
+	if validationErr != nil {
+		retry := request
+		retry.Message = fmt.Sprintf(
+			"Your answer does not match the JSON schema:\n%v\nAnswer again with JSON matching the schema only.",
+			validationErr,
+		)
+
+		response, err = llm.Prompt(ctx, retry)
+		if err != nil || response.Refusal != "" || response.Incomplete {
+			return response, err
+		}
+
+		validationErr = request.Schema.Validate([]byte(response.Text))
+		if validationErr != nil {
+			return models.Response{}, fmt.Errorf("the answer does not match the '%s' JSON schema: %w", request.Schema.Name, validationErr)
+		}
+	}
+
+	return formatJSON(response), nil
+}
+
+// formatJSON pretty prints the JSON answer within a code block, so it gets highlighted.
+func formatJSON(response models.Response) models.Response {
+	indented, err := schema.Indent([]byte(response.Text))
+	if err != nil {
+		return response
+	}
+
+	response.Text = "```json\n" + indented + "\n```"
+	return response
+}
@@ -24,7 +24,9 @@ const (
 	// ViewPortReducerWidth is the amount of characters to reduce so the borders do not touch the edges of the terminal window
 	ViewPortReducerHeight int = 7
 
-	BorderColor = "8"
+	BorderColor  = "8"
+	WarningColor = "3"
+	RefusalColor = "1"
 )
 
 var (
@@ -42,6 +44,25 @@ var (
 	fadedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color(BorderColor))
 
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(WarningColor))
+
+	refusalStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(RefusalColor)).
+			Italic(true)
+
+	lowFindingStyle = lipgloss.NewStyle().
+			Underline(true)
+
+	mediumFindingStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color(WarningColor)).
+				Underline(true)
+
+	highFindingStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color(RefusalColor)).
+				Bold(true).
+				Underline(true)
+
 	// spinnerStyle = lipgloss.NewStyle().
 	// 		Foreground(lipgloss.Color(TextHighlightColor)).
 	// 		Border(lipgloss.HiddenBorder()).
@@ -54,6 +75,9 @@ var (
 	skillStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder())
 
+	compareStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder())
+
 	statusBarStyle = lipgloss.NewStyle().
 			Border(lipgloss.NormalBorder(), true, true, true, true).
 			BorderForeground(lipgloss.Color(BorderColor)).
@@ -108,6 +132,9 @@ func (m model) viewPortFooterView() string {
 	}
 
 	modelName := infoStyle.Foreground(lipgloss.Color(BorderColor)).Render(m.llm.GetModel())
+	if m.isCompareMode() {
+		modelName = infoStyle.Foreground(lipgloss.Color(BorderColor)).Render("compare mode")
+	}
 	scrollPercent := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
 	borderLines := strings.Repeat("─", getMax(0, m.viewportCurrentWidth-lipgloss.Width(scrollPercent)-lipgloss.Width(modelName)))
 
@@ -0,0 +1,216 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycruz/gail/internal/assistant"
+)
+
+const templateStatusMessage = "Fill the variables of the skill: 'tab':next, 'shift+tab':previous, '←/→':change choice, 'enter':next or apply, 'esc':cancel"
+
+// templateField is a variable of the skill template form.
+type templateField struct {
+	variable assistant.Variable
+	input    textinput.Model // Typed value, unused by the variables with choices
+	choice   int             // Index of the picked choice
+}
+
+// value returns the value of the field.
+func (f templateField) value() string {
+	if len(f.variable.Choices) > 0 {
+		return f.variable.Choices[f.choice]
+	}
+	return f.input.Value()
+}
+
+// renderDefaults fills the placeholders of the skill with the defaults of its variables and the built-in values.
+func renderDefaults(skill assistant.Skill) assistant.Skill {
+	if !skill.IsTemplate() {
+		return skill
+	}
+	builtins := assistant.BuiltinValues()
+	return skill.Render(assistant.Defaults(skill.Prompted(builtins)), builtins)
+}
+
+// selectSkill makes the skill the current one. The skills with variables to fill open the
+// template form first, and their built-in placeholders are filled right away.
+func (m model) selectSkill(skill assistant.Skill) (model, tea.Cmd) {
+	if !skill.IsTemplate() {
+		return m.applySkill(skill), nil
+	}
+
+	builtins := assistant.BuiltinValues()
+	variables := skill.Prompted(builtins)
+	if len(variables) == 0 {
+		return m.applySkill(skill.Render(nil, builtins)), nil
+	}
+
+	defaults := assistant.Defaults(variables)
+	fields := make([]templateField, 0, len(variables))
+	for _, variable := range variables {
+		field := templateField{variable: variable}
+		if len(variable.Choices) > 0 {
+			for i, choice := range variable.Choices {
+				if choice == defaults[variable.Name] {
+					field.choice = i
+				}
+			}
+		} else {
+			field.input = textinput.New()
+			field.input.Prompt = ""
+			field.input.SetValue(defaults[variable.Name])
+		}
+		fields = append(fields, field)
+	}
+
+	m.isTemplatePrompt = true
+	m.templateSkill = skill
+	m.templateBuiltins = builtins
+	m.templateFields = fields
+	m.templateFocus = 0
+	cmd := m.focusTemplateField()
+	m.statusBarMessage = templateStatusMessage
+	return m, cmd
+}
+
+// applySkill makes the skill the current one and goes back to the prompt.
+func (m model) applySkill(skill assistant.Skill) model {
+	m.skill = skill
+	m.focusOnTextArea = true
+	m.textarea.Focus()
+	return m
+}
+
+// closeTemplateForm leaves the template form, applying the skill with the values filled when confirmed.
+func (m model) closeTemplateForm(confirmed bool) model {
+	if confirmed {
+		values := make(map[string]string, len(m.templateFields))
+		for _, field := range m.templateFields {
+			values[field.variable.Name] = field.value()
+		}
+		m = m.applySkill(m.templateSkill.Render(values, m.templateBuiltins))
+	} else {
+		m.focusOnTextArea = true
+		m.textarea.Focus()
+	}
+
+	m.isTemplatePrompt = false
+	m.templateSkill = assistant.Skill{}
+	m.templateBuiltins = nil
+	m.templateFields = nil
+	m.statusBarMessage = m.defaultStatus()
+	return m
+}
+
+// focusTemplateField focuses the text input of the focused field, and blurs the others.
+// It returns the command blinking the cursor of the focused input.
+func (m *model) focusTemplateField() tea.Cmd {
+	var cmd tea.Cmd
+	for i, field := range m.templateFields {
+		if len(field.variable.Choices) > 0 {
+			continue
+		}
+		if i == m.templateFocus {
+			cmd = m.templateFields[i].input.Focus()
+		} else {
+			m.templateFields[i].input.Blur()
+		}
+	}
+	return cmd
+}
+
+// updateTemplateForm handles the keys of the template form.
+func (m model) updateTemplateForm(msg tea.KeyMsg) (model, tea.Cmd) {
+	field := &m.templateFields[m.templateFocus]
+
+	switch msg.Type {
+	case tea.KeyCtrlQ:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		return m.closeTemplateForm(false), nil
+	case tea.KeyEnter:
+		if m.templateFocus == len(m.templateFields)-1 {
+			return m.closeTemplateForm(true), nil
+		}
+		m.templateFocus++
+	case tea.KeyTab, tea.KeyDown:
+		m.templateFocus = (m.templateFocus + 1) % len(m.templateFields)
+	case tea.KeyShiftTab, tea.KeyUp:
+		m.templateFocus = (m.templateFocus + len(m.templateFields) - 1) % len(m.templateFields)
+	case tea.KeyLeft, tea.KeyRight:
+		if len(field.variable.Choices) == 0 {
+			var cmd tea.Cmd
+			field.input, cmd = field.input.Update(msg)
+			return m, cmd
+		}
+		step := 1
+		if msg.Type == tea.KeyLeft {
+			step = len(field.variable.Choices) - 1
+		}
+		field.choice = (field.choice + step) % len(field.variable.Choices)
+		return m, nil
+	default:
+		if len(field.variable.Choices) > 0 {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		field.input, cmd = field.input.Update(msg)
+		return m, cmd
+	}
+
+	cmd := m.focusTemplateField()
+	return m, cmd
+}
+
+// updateTemplateField passes the messages other than the keys, such as the blinks of the cursor,
+// to the text input of the focused field.
+func (m model) updateTemplateField(msg tea.Msg) (model, tea.Cmd) {
+	field := &m.templateFields[m.templateFocus]
+	if len(field.variable.Choices) > 0 {
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	field.input, cmd = field.input.Update(msg)
+	return m, cmd
+}
+
+// templateFormView shows the variables of the skill being picked, and its instruction filled with their current values.
+func (m model) templateFormView() string {
+	values := make(map[string]string, len(m.templateFields))
+	for _, field := range m.templateFields {
+		values[field.variable.Name] = field.value()
+	}
+	preview := m.templateSkill.Render(values, m.templateBuiltins).Instruction
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf(" Skill: %s ", m.templateSkill.ID)))
+	b.WriteString("\n\n")
+
+	nameWidth := 0
+	for _, field := range m.templateFields {
+		nameWidth = getMax(nameWidth, len(field.variable.Name))
+	}
+	for i, field := range m.templateFields {
+		cursor := "  "
+		if i == m.templateFocus {
+			cursor = "> "
+		}
+
+		value := field.input.View()
+		if len(field.variable.Choices) > 0 {
+			value = fmt.Sprintf("‹ %s ›", field.value())
+			if len(field.variable.Choices) > 1 {
+				value += fadedStyle.Render(fmt.Sprintf("  (%d/%d)", field.choice+1, len(field.variable.Choices)))
+			}
+		}
+		fmt.Fprintf(&b, "%s%-*s  %s\n", cursor, nameWidth, field.variable.Name, value)
+	}
+
+	b.WriteString("\n")
+	b.WriteString(fadedStyle.Render(preview))
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nycruz/gail/internal/assistant"
+)
+
+func TestTemplateFieldCursorBlinks(t *testing.T) {
+	skill := assistant.Skill{
+		ID:          "code-review",
+		Instruction: "Review this code for {{focus}}.",
+		Variables:   []assistant.Variable{{Name: "focus", Default: "readability"}},
+	}
+	a := &assistant.Assistant{
+		Roles:  []assistant.Role{{ID: "swe", Name: "Software Engineer"}},
+		Skills: []assistant.Skill{skill},
+	}
+	m := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, a, nil, 0)
+
+	m, cmd := m.selectSkill(skill)
+	if cmd == nil {
+		t.Fatal("opening the template form returns no command blinking the cursor")
+	}
+
+	for i := 0; i < 2; i++ {
+		msg, ok := blinkMsg(cmd)
+		if !ok {
+			t.Fatalf("the commands of blink %d return no cursor.BlinkMsg", i+1)
+		}
+
+		blink := m.templateFields[0].input.Cursor.Blink
+		updated, next := m.Update(msg)
+		m = updated.(model)
+		if m.templateFields[0].input.Cursor.Blink == blink {
+			t.Fatalf("the cursor did not toggle on blink %d", i+1)
+		}
+		cmd = next
+	}
+}
+
+// blinkMsg runs the command, or the commands of the batch, and returns the blink of a cursor among their messages.
+func blinkMsg(cmd tea.Cmd) (cursor.BlinkMsg, bool) {
+	if cmd == nil {
+		return cursor.BlinkMsg{}, false
+	}
+
+	switch msg := cmd().(type) {
+	case cursor.BlinkMsg:
+		return msg, true
+	case tea.BatchMsg:
+		for _, c := range msg {
+			if blink, ok := blinkMsg(c); ok {
+				return blink, true
+			}
+		}
+	}
+	return cursor.BlinkMsg{}, false
+}
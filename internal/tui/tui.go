@@ -1,7 +1,6 @@
 package tui
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -18,14 +17,10 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/muesli/reflow/wordwrap"
 	"github.com/nycruz/gail/internal/assistant"
+	"github.com/nycruz/gail/internal/models"
+	"github.com/nycruz/gail/internal/validator"
 )
 
-type LLM interface {
-	Prompt(ctx context.Context, roleName string, rolePersona string, skillInstruction string, message string) (string, error)
-	GetModel() string
-	GetUser() string
-}
-
 // Interface Guard for Model
 // Ensure Model implements tea.Model
 var _ tea.Model = (*model)(nil)
@@ -43,6 +38,11 @@ type model struct {
 	focusOnTextArea bool           // Focus on textarea
 
 	statusBarMessage string
+	retryCmd         tea.Cmd // Command sending the last prompt again after a failure
+	canContinue      bool    // Whether the last answer is incomplete and can be continued
+	autoContinue     int     // How many times in a row an incomplete answer is continued without asking
+	continueRounds   int     // How many times the last answer was continued without asking
+	lastAnswer       string  // Last answer before syntax highlighting
 
 	assistant    *assistant.Assistant // Assistant
 	isRolePrompt bool                 // Role prompt state
@@ -53,7 +53,22 @@ type model struct {
 	skillList     list.Model      // List for displaying skills
 	skill         assistant.Skill // Current Skill
 
-	llm LLM // Large Language Model
+	isTemplatePrompt bool              // Skill template form state
+	templateSkill    assistant.Skill   // Skill whose variables are being filled
+	templateBuiltins map[string]string // Values of the built-in variables when the skill was picked
+	templateFields   []templateField   // Variables of the skill template
+	templateFocus    int               // Index of the focused variable
+
+	llm models.LLM // Large Language Model
+
+	validator           *validator.Validator // Validator checking the input before sending it
+	isValidating        bool                 // Whether the input is being validated before sending it
+	isWarnConfirmPrompt bool                 // Warning confirmation prompt state
+	warnFindings        []validator.Finding  // Findings waiting for the user to confirm sending the input
+
+	compareColumns      []compareColumn // Models answering side by side in compare mode
+	isComparePickPrompt bool            // Compare pick prompt state
+	compareList         list.Model      // List for picking the model to continue with
 
 	viewportCurrentWidth  int // Current width of the window
 	viewportCurrentHeight int // Current height of the window
@@ -70,9 +85,10 @@ type clearStatusBarMsg struct{}
 const (
 	clearStatusBarAfterSeconds time.Duration = 10
 	defaultStatusMessage       string        = "'ctrl-q':quit, 'ctrl+s':send, 'ctrl+r':pick role, 'ctrl+e':pick skill, 'ctrl+d':save conversation, 'ctrl+c':copy conversation"
+	compareStatusMessage       string        = ", 'ctrl+b':pick compared model"
 )
 
-func New(logger *slog.Logger, mdl LLM, assistant *assistant.Assistant) model {
+func New(logger *slog.Logger, mdl models.LLM, compare []models.LLM, assistant *assistant.Assistant, validator *validator.Validator, autoContinue int) model {
 	ta := setupTextArea()
 	vp := setupViewPort()
 	s := setupSpinner()
@@ -82,7 +98,7 @@ func New(logger *slog.Logger, mdl LLM, assistant *assistant.Assistant) model {
 	defaultRole := assistant.DefaultRole()
 
 	skills := setupSkills(assistant.Skills)
-	defaultSkill := assistant.DefaultSkill()
+	defaultSkill := renderDefaults(assistant.DefaultSkill())
 
 	return model{
 		textarea:         ta,
@@ -93,7 +109,7 @@ func New(logger *slog.Logger, mdl LLM, assistant *assistant.Assistant) model {
 		receiverStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
 		helpSection:      h,
 		focusOnTextArea:  true,
-		statusBarMessage: defaultStatusMessage,
+		statusBarMessage: shortcutsStatus(len(compare) > 0),
 		messagesDisplay:  []string{},
 		assistant:        assistant,
 		roleList:         roles,
@@ -102,7 +118,11 @@ func New(logger *slog.Logger, mdl LLM, assistant *assistant.Assistant) model {
 		skillList:        skills,
 		isSkillPrompt:    false,
 		skill:            defaultSkill,
+		autoContinue:     autoContinue,
 		llm:              mdl,
+		validator:        validator,
+		compareColumns:   setupCompareColumns(compare),
+		compareList:      setupCompareList(),
 		logger:           logger,
 		err:              nil,
 	}
@@ -126,6 +146,14 @@ func (m model) View() string {
 		return skillStyle.Render(m.skillList.View())
 	}
 
+	if m.isTemplatePrompt {
+		return skillStyle.Render(m.templateFormView())
+	}
+
+	if m.isComparePickPrompt {
+		return compareStyle.Render(m.compareList.View())
+	}
+
 	if m.focusOnTextArea {
 		textAreaStyle = textAreaStyle.BorderForeground(lipgloss.Color(TextHighlightColor))
 		viewPortStyle = viewPortStyle.BorderForeground(lipgloss.Color(BorderColor))
@@ -134,16 +162,28 @@ func (m model) View() string {
 		textAreaStyle = textAreaStyle.BorderForeground(lipgloss.Color(BorderColor))
 	}
 
-	if m.isLoading {
+	if m.isValidating {
+		m.statusBarMessage = fmt.Sprintf("%s checking the input...", m.spinner.View())
+	} else if m.isLoading {
 		m.statusBarMessage = fmt.Sprintf("%s thinking...", m.spinner.View())
 	}
 
+	textAreaContent := m.textarea.View()
+	if m.isWarnConfirmPrompt {
+		textAreaContent = m.warnConfirmView()
+	}
+
+	viewportContent := m.viewport.View()
+	if m.isCompareMode() {
+		viewportContent = m.compareView()
+	}
+
 	return lipgloss.JoinVertical(lipgloss.Top,
 		m.viewportHeaderView(),
-		viewPortStyle.Render(m.viewport.View()),
+		viewPortStyle.Render(viewportContent),
 		m.viewPortFooterView(),
 		m.textAreaHeaderView(),
-		textAreaStyle.Render(m.textarea.View()),
+		textAreaStyle.Render(textAreaContent),
 		statusBarStyle.Render(m.statusBarMessage),
 	)
 }
@@ -155,6 +195,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		sCmd  tea.Cmd
 		rlCmd tea.Cmd
 		slCmd tea.Cmd
+		clCmd tea.Cmd
+		tfCmd tea.Cmd
 	)
 
 	// First, update the textarea
@@ -163,10 +205,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Conditionally update the viewport only if the textarea is not focused
 	if !m.focusOnTextArea {
 		m.viewport, vpCmd = m.viewport.Update(msg)
+		m.ownCompareColumns()
+		for i := range m.compareColumns {
+			m.compareColumns[i].viewport, _ = m.compareColumns[i].viewport.Update(msg)
+		}
 	}
 
 	m.roleList, rlCmd = m.roleList.Update(msg)
 	m.skillList, slCmd = m.skillList.Update(msg)
+	m.compareList, clCmd = m.compareList.Update(msg)
+
+	// The focused field of the template form gets the other messages than the keys, such as the blinks of its cursor
+	if _, isKey := msg.(tea.KeyMsg); m.isTemplatePrompt && !isKey {
+		m, tfCmd = m.updateTemplateField(msg)
+	}
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -176,6 +228,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// Enter to send the input despite the warnings, 'a' to also allow the values for the session, Esc to edit it
+		if m.isWarnConfirmPrompt {
+			if msg.String() == "a" {
+				m.validator.AllowForSession(m.warnFindings)
+				return m.confirmWarnings(true)
+			}
+			switch msg.Type {
+			case tea.KeyEnter:
+				return m.confirmWarnings(true)
+			case tea.KeyEsc:
+				return m.confirmWarnings(false)
+			case tea.KeyCtrlQ:
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		// Keys filling the variables of the picked skill
+		if m.isTemplatePrompt {
+			return m.updateTemplateForm(msg)
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlQ:
 			return m, tea.Quit
@@ -200,15 +274,37 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Ctrl+S to send the message
 		case tea.KeyCtrlS:
-			m.textAreaContent = m.textarea.Value()
-			m.textarea.Reset()
+			return m.checkAndSend()
+
+		// Ctrl+Y to retry the last prompt after a failure
+		case tea.KeyCtrlY:
+			if m.retryCmd == nil || m.isLoading {
+				return m, nil
+			}
+			m.isLoading = true
+			return m, tea.Batch(m.spinner.Tick, m.retryCmd)
+
+		// Ctrl+O to continue an incomplete answer
+		case tea.KeyCtrlO:
+			if !m.canContinue || m.isLoading {
+				return m, nil
+			}
+			m.isLoading = true
+			m.canContinue = false
+			m.continueRounds = 0
+			m.retryCmd = m.continueAnswer(m.lastAnswer)
+			return m, tea.Batch(m.spinner.Tick, m.retryCmd)
+
+		// Ctrl+B to pick the compared model to continue the conversation with
+		case tea.KeyCtrlB:
+			if !m.isCompareMode() || m.isLoading {
+				return m, nil
+			}
+			m.isComparePickPrompt = true
 			m.textarea.Blur()
 			m.focusOnTextArea = false
-			m.isLoading = true
-			return m, tea.Batch(
-				m.spinner.Tick,
-				m.fetchAnswer(m.role.Name, m.role.Persona, m.skill.Instruction, m.textAreaContent),
-			)
+
+			return m, m.compareList.SetItems(m.compareItems())
 
 		// Ctrl+R to pick a role
 		case tea.KeyCtrlR:
@@ -248,8 +344,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				m.role.Persona = c.FilterValue()
-				m.role.ID = c.ID()
+				m.role = m.assistant.FindRoleByID(c.ID())
 				m.isRolePrompt = false
 				m.focusOnTextArea = true
 				m.textarea.Focus()
@@ -263,8 +358,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				m.skill.Instruction = c.FilterValue()
 				m.isSkillPrompt = false
+				return m.selectSkill(m.assistant.FindSkillByID(c.ID()))
+			}
+			if m.isComparePickPrompt {
+				c, ok := m.compareList.SelectedItem().(CompareItem)
+				if !ok {
+					compareSelectError := "internal error: could not select the model to continue with"
+					m.logger.Info(compareSelectError)
+					m.err = errors.New(compareSelectError)
+					return m, nil
+				}
+
+				m = m.selectCompareColumn(c.index)
+				m.isComparePickPrompt = false
 				m.focusOnTextArea = true
 				m.textarea.Focus()
 			}
@@ -286,31 +393,89 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, clearStatusBarAfter(clearStatusBarAfterSeconds * time.Second)
 
+	case validatedInput:
+		return m.handleValidatedInput(msg)
+
 	case Answer:
+		m.isLoading = false
+
 		if msg.Error != nil {
-			m.statusBarMessage = fmt.Sprintf("Error fetching answer: %v", msg.Error)
-		} else {
-			m.statusBarMessage = msg.msg
+			// The failed prompt is kept out of the conversation so it can be retried.
+			m.logger.Error("LLM prompt failed", slog.String("error", msg.Error.Error()))
+			errorMessage, retryable := describeError(msg.Error)
+			if retryable {
+				errorMessage = fmt.Sprintf("%s Press 'ctrl+y' to retry.", errorMessage)
+			} else {
+				m.retryCmd = nil
+			}
+			m.statusBarMessage = errorMessage
+			return m, nil
 		}
 
-		userPrompt := m.senderStyle.Render("You: ") + m.textAreaContent
-		userPrompt = wordwrap.String(userPrompt, m.viewportCurrentWidth-ReducerWidthForBorder)
+		m.retryCmd = nil
+		m.statusBarMessage = msg.msg
 
-		gailPrompt := m.receiverStyle.Render("\nGail: ") + msg.Answer + "\n"
-		gailPrompt = wordwrap.String(gailPrompt, m.viewportCurrentWidth-ReducerWidthForBorder)
+		gailLabel := "\nGail: "
+		if m.isFallbackAnswer(msg) {
+			// Record which model actually answered in the conversation.
+			gailLabel = fmt.Sprintf("\nGail (%s): ", msg.model)
+		}
+
+		gailPrompt := m.receiverStyle.Render(gailLabel) + msg.Answer
+		if len(msg.substitutions) > 0 {
+			gailPrompt += "\n" + warningStyle.Render(substitutionsNotice(msg.substitutions))
+		}
+		if len(msg.flags) > 0 {
+			gailPrompt += "\n" + warningStyle.Render(flagsNotice(msg.raw, msg.flags))
+			m.statusBarMessage = fmt.Sprintf("The answer has %d flagged line(s): check them before running or copying them.", len(flaggedLines(msg.raw, msg.flags)))
+		}
+		if msg.refusal != "" {
+			gailPrompt += "\n" + refusalStyle.Render("Refused: "+msg.refusal)
+		}
 
-		m.messagesDisplay = append(m.messagesDisplay, userPrompt, gailPrompt)
+		m.canContinue = msg.isIncomplete
+		m.lastAnswer = msg.raw
+		if msg.isIncomplete {
+			notice := fmt.Sprintf("[answer incomplete: %s. Press 'ctrl+o' to continue]", incompleteReasonOrDefault(msg.incompleteReason))
+			gailPrompt += "\n" + warningStyle.Render(notice)
+			m.statusBarMessage = fmt.Sprintf("The answer is incomplete (%s). Press 'ctrl+o' to continue.", incompleteReasonOrDefault(msg.incompleteReason))
+		}
+
+		gailPrompt = wordwrap.String(gailPrompt+"\n", m.viewportCurrentWidth-ReducerWidthForBorder)
+
+		if msg.isContinuation && len(m.messagesDisplay) > 0 {
+			// Replace the previous, incomplete, answer with the continued one.
+			m.messagesDisplay[len(m.messagesDisplay)-1] = gailPrompt
+		} else {
+			userPrompt := m.senderStyle.Render("You: ") + m.textAreaContent
+			userPrompt = wordwrap.String(userPrompt, m.viewportCurrentWidth-ReducerWidthForBorder)
+			m.messagesDisplay = append(m.messagesDisplay, userPrompt, gailPrompt)
+		}
 
 		m.viewport.SetContent(strings.Join(m.messagesDisplay, "\n"))
 		m.viewport.GotoBottom()
-		m.isLoading = false
 
 		unformmatedAnswer := removeANSICodes(strings.Join(m.messagesDisplay, "\n"))
+		if m.canContinue && m.continueRounds < m.autoContinue {
+			m.continueRounds++
+			m.isLoading = true
+			m.canContinue = false
+			m.retryCmd = m.continueAnswer(m.lastAnswer)
+			m.statusBarMessage = fmt.Sprintf("The answer is incomplete (%s). Continuing it (%d/%d)...", incompleteReasonOrDefault(msg.incompleteReason), m.continueRounds, m.autoContinue)
+			return m, tea.Batch(m.saveConversation(unformmatedAnswer), m.spinner.Tick, m.retryCmd)
+		}
+		if m.canContinue {
+			// Keep the continue hint in the status bar.
+			return m, m.saveConversation(unformmatedAnswer)
+		}
 		return m, tea.Batch(m.saveConversation(unformmatedAnswer), clearStatusBarAfter(clearStatusBarAfterSeconds*time.Second))
 
+	case compareAnswer:
+		return m.handleCompareAnswer(msg)
+
 	// Clear the status bar when the timer expires
 	case clearStatusBarMsg:
-		m.statusBarMessage = defaultStatusMessage
+		m.statusBarMessage = m.defaultStatus()
 		return m, nil
 
 	case tea.WindowSizeMsg:
@@ -359,6 +524,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.skillList.SetWidth(msg.Width - ReducerWidth)
 		m.skillList.SetHeight(msg.Height - ReducerWidth)
 
+		// compare columns and list sizes
+		m = m.resizeCompareColumns()
+
+		compareStyle.Width(msg.Width - ReducerWidth)
+		compareStyle.Height(viewportHeightWithBorder)
+
+		m.compareList.SetWidth(msg.Width - ReducerWidth)
+		m.compareList.SetHeight(msg.Height - ReducerWidth)
+
 	case spinner.TickMsg:
 		m.spinner, sCmd = m.spinner.Update(msg)
 
@@ -367,7 +541,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	return m, tea.Batch(tiCmd, vpCmd, sCmd, rlCmd, slCmd)
+	return m, tea.Batch(tiCmd, vpCmd, sCmd, rlCmd, slCmd, clCmd, tfCmd)
 }
 
 func setupTextArea() textarea.Model {
@@ -413,6 +587,7 @@ func roleList(roles []assistant.Role) []list.Item {
 			id:      string(role.ID),
 			name:    role.Name,
 			persona: role.Persona,
+			source:  role.Source,
 		})
 	}
 
@@ -438,12 +613,35 @@ func skillList(skills []assistant.Skill) []list.Item {
 			id:          skill.ID,
 			instruction: skill.Instruction,
 			description: skill.Description,
+			source:      skill.Source,
 		})
 	}
 
 	return skillItems
 }
 
+// shortcutsStatus returns the status bar message listing the shortcuts, including the one
+// picking the compared model in compare mode.
+func shortcutsStatus(isCompareMode bool) string {
+	if isCompareMode {
+		return defaultStatusMessage + compareStatusMessage
+	}
+	return defaultStatusMessage
+}
+
+// defaultStatus returns the status bar message listing the shortcuts available to the model.
+func (m model) defaultStatus() string {
+	return shortcutsStatus(m.isCompareMode())
+}
+
+// incompleteReasonOrDefault returns a readable reason for an incomplete answer.
+func incompleteReasonOrDefault(reason string) string {
+	if reason == "" {
+		return "unknown reason"
+	}
+	return reason
+}
+
 func removeANSICodes(input string) string {
 	ansi := regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
 	return ansi.ReplaceAllString(input, "")
@@ -0,0 +1,99 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// AllowlistFileName is the name of the global allowlist file in the configuration directory.
+const AllowlistFileName = "allowlist"
+
+// AllowlistConfig holds the content of the global allowlist file.
+type AllowlistConfig struct {
+	Allow []string `mapstructure:"allow"`
+}
+
+// allowlist holds the values that validation rules must not report.
+type allowlist struct {
+	// Regular expressions the whole value must match.
+	regexes []*regexp.Regexp
+	// Networks the value must be an IP address of.
+	networks []*net.IPNet
+}
+
+// newAllowlist compiles the allowlist entries. An entry is either a network in CIDR
+// notation (e.g. 192.0.2.0/24) or a regular expression matching the whole value
+// (e.g. .*@ourcompany\.com).
+func newAllowlist(entries []string) (allowlist, error) {
+	var a allowlist
+	var errs []error
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			a.networks = append(a.networks, network)
+			continue
+		}
+
+		regex, err := regexp.Compile(`^(?:` + entry + `)$`)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allow '%s': %w", entry, err))
+			continue
+		}
+		a.regexes = append(a.regexes, regex)
+	}
+
+	return a, errors.Join(errs...)
+}
+
+// allows reports whether the value is allowed.
+func (a allowlist) allows(value string) bool {
+	for _, regex := range a.regexes {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+
+	if len(a.networks) > 0 {
+		if ip := net.ParseIP(value); ip != nil {
+			for _, network := range a.networks {
+				if network.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// readAllowlist reads the global allowlist file of the configuration directory, if there is one.
+func readAllowlist(configDirPath string) (allowlist, error) {
+	path := filepath.Join(configDirPath, AllowlistFileName+".toml")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return allowlist{}, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return allowlist{}, fmt.Errorf("failed to read the '%s' allowlist file: %w", path, err)
+	}
+
+	var ac AllowlistConfig
+	if err := v.Unmarshal(&ac); err != nil {
+		return allowlist{}, fmt.Errorf("failed to unmarshal the '%s' allowlist file: %w", path, err)
+	}
+
+	a, err := newAllowlist(ac.Allow)
+	if err != nil {
+		return allowlist{}, fmt.Errorf("invalid entries in the '%s' allowlist file:\n%w", path, err)
+	}
+
+	return a, nil
+}
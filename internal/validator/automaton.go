@@ -0,0 +1,95 @@
+package validator
+
+// automaton is an Aho-Corasick automaton finding which of a set of literals occur in an input
+// in a single pass. The literals are matched ignoring the case of ASCII letters.
+type automaton struct {
+	// The transitions of each state on each byte, failure links included.
+	transitions [][256]int32
+	// The literals recognized when reaching each state, by index.
+	outputs [][]int
+}
+
+// newAutomaton builds the automaton of the given literals.
+func newAutomaton(literals []string) *automaton {
+	a := &automaton{
+		transitions: make([][256]int32, 1),
+		outputs:     make([][]int, 1),
+	}
+
+	// The trie of the literals, with -1 standing for the missing transitions.
+	for i := range a.transitions[0] {
+		a.transitions[0][i] = -1
+	}
+	for index, literal := range literals {
+		state := int32(0)
+		for i := 0; i < len(literal); i++ {
+			c := lowerASCII(literal[i])
+			if a.transitions[state][c] == -1 {
+				var next [256]int32
+				for j := range next {
+					next[j] = -1
+				}
+				a.transitions = append(a.transitions, next)
+				a.outputs = append(a.outputs, nil)
+				a.transitions[state][c] = int32(len(a.transitions) - 1)
+			}
+			state = a.transitions[state][c]
+		}
+		a.outputs[state] = append(a.outputs[state], index)
+	}
+
+	// The failure links, computed breadth first, are folded into the transitions
+	// so that scanning takes one lookup per byte.
+	failures := make([]int32, len(a.transitions))
+	var queue []int32
+	for c := 0; c < 256; c++ {
+		if next := a.transitions[0][c]; next == -1 {
+			a.transitions[0][c] = 0
+		} else {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		a.outputs[state] = append(a.outputs[state], a.outputs[failures[state]]...)
+		for c := 0; c < 256; c++ {
+			next := a.transitions[state][c]
+			if next == -1 {
+				a.transitions[state][c] = a.transitions[failures[state]][c]
+				continue
+			}
+			failures[next] = a.transitions[failures[state]][c]
+			queue = append(queue, next)
+		}
+	}
+
+	// The upper-case letters go where their lower-case counterparts do.
+	for state := range a.transitions {
+		for c := 'A'; c <= 'Z'; c++ {
+			a.transitions[state][c] = a.transitions[state][c+'a'-'A']
+		}
+	}
+
+	return a
+}
+
+// find calls found with the index of each literal occurring in the input, and the offset
+// of the end of the occurrence, in increasing order of the end offsets.
+func (a *automaton) find(input string, found func(index int, end int)) {
+	state := int32(0)
+	for i := 0; i < len(input); i++ {
+		state = a.transitions[state][input[i]]
+		for _, index := range a.outputs[state] {
+			found(index, i+1)
+		}
+	}
+}
+
+// lowerASCII returns the lower-case counterpart of an ASCII upper-case letter, and any other byte as is.
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
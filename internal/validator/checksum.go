@@ -0,0 +1,419 @@
+package validator
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kind is a check run on the matches of a validation rule's pattern,
+// so that only the candidates passing it are reported.
+type Kind string
+
+const (
+	// KindRegex reports every match of the pattern.
+	KindRegex Kind = ""
+	// KindLuhn reports the payment card numbers passing the Luhn checksum.
+	KindLuhn Kind = "luhn"
+	// KindIBAN reports the International Bank Account Numbers passing the mod-97 check.
+	KindIBAN Kind = "iban"
+	// KindSSN reports the US Social Security Numbers following the area, group and serial rules.
+	KindSSN Kind = "ssn"
+	// KindNINO reports the UK National Insurance numbers with a prefix that can be issued.
+	KindNINO Kind = "nino"
+	// KindNHS reports the UK NHS numbers passing the mod-11 check.
+	KindNHS Kind = "nhs"
+	// KindCPF reports the Brazilian individual taxpayer numbers passing their two check digits.
+	KindCPF Kind = "cpf"
+	// KindCNPJ reports the Brazilian company registration numbers passing their two check digits.
+	KindCNPJ Kind = "cnpj"
+	// KindAadhaar reports the Indian Aadhaar numbers passing the Verhoeff checksum.
+	KindAadhaar Kind = "aadhaar"
+	// KindVAT reports the EU VAT identification numbers passing the check of their member state, if it has one.
+	KindVAT Kind = "vat"
+)
+
+// kindChecks are the checks of the kinds, and the pattern used to find candidates when the rule has none.
+var kindChecks = map[Kind]struct {
+	pattern string
+	verify  func(match string) bool
+}{
+	KindLuhn:    {pattern: `\b(?:\d[ -]?){12,18}\d\b`, verify: isLuhnValid},
+	KindIBAN:    {pattern: `\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`, verify: isIBANValid},
+	KindSSN:     {pattern: `\b\d{3}-?\d{2}-?\d{4}\b`, verify: isSSNValid},
+	KindNINO:    {pattern: `\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`, verify: isNINOValid},
+	KindNHS:     {pattern: `\b\d{3}[ -]?\d{3}[ -]?\d{4}\b`, verify: isNHSValid},
+	KindCPF:     {pattern: `\b\d{3}\.?\d{3}\.?\d{3}-?\d{2}\b`, verify: isCPFValid},
+	KindCNPJ:    {pattern: `\b\d{2}\.?\d{3}\.?\d{3}/?\d{4}-?\d{2}\b`, verify: isCNPJValid},
+	KindAadhaar: {pattern: `\b[2-9]\d{3}[ -]?\d{4}[ -]?\d{4}\b`, verify: isAadhaarValid},
+	// The VAT identification number formats of the member states, prefixed with their country code.
+	KindVAT: {pattern: `\b(?:ATU\d{8}|BE[01]\d{9}|BG\d{9,10}|CY\d{8}[A-Z]|CZ\d{8,10}|DE\d{9}|DK\d{8}|EE\d{9}|EL\d{9}|ES[A-Z0-9]\d{7}[A-Z0-9]|FI\d{8}|FR[A-HJ-NP-Z0-9]{2}\d{9}|HR\d{11}|HU\d{8}|IE\d{7}[A-W][A-I]?|IT\d{11}|LT(?:\d{9}|\d{12})|LU\d{8}|LV\d{11}|MT\d{8}|NL\d{9}B\d{2}|PL\d{10}|PT\d{9}|RO\d{2,10}|SE\d{12}|SI\d{8}|SK\d{10})\b`, verify: isVATValid},
+}
+
+// kindNames returns the names of the kinds having a check, in alphabetical order.
+func kindNames() string {
+	names := make([]string, 0, len(kindChecks))
+	for kind := range kindChecks {
+		names = append(names, string(kind))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// isLuhnValid reports whether the digits of the match, ignoring spaces and dashes, pass the Luhn checksum.
+func isLuhnValid(match string) bool {
+	digits := stripSeparators(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	return isDigits(digits) && isLuhnChecksum(digits)
+}
+
+// isIBANValid reports whether the match, ignoring spaces, is an IBAN passing the mod-97 check.
+func isIBANValid(match string) bool {
+	iban := strings.ToUpper(stripSeparators(match))
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	if !unicode.IsLetter(rune(iban[0])) || !unicode.IsLetter(rune(iban[1])) {
+		return false
+	}
+
+	// The country code and check digits are moved to the end, and the letters turned into numbers (A=10 ... Z=35).
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+
+	return remainder == 1
+}
+
+// isSSNValid reports whether the match is a US Social Security Number that can be issued:
+// the area is neither 000, 666 nor 900-999, the group is not 00 and the serial is not 0000.
+func isSSNValid(match string) bool {
+	digits := stripSeparators(match)
+	if len(digits) != 9 {
+		return false
+	}
+
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	switch {
+	case area == "000", area == "666", area[0] == '9':
+		return false
+	case group == "00":
+		return false
+	case serial == "0000":
+		return false
+	}
+
+	return true
+}
+
+// isNINOValid reports whether the match is a UK National Insurance number whose prefix can be issued.
+// The letter rules are enforced by the pattern, only the prefixes never used are checked here.
+func isNINOValid(match string) bool {
+	nino := strings.ToUpper(stripSeparators(match))
+	if len(nino) != 9 {
+		return false
+	}
+
+	switch nino[:2] {
+	case "BG", "GB", "KN", "NK", "NT", "TN", "ZZ":
+		return false
+	}
+	return true
+}
+
+// isNHSValid reports whether the match is a UK NHS number passing the mod-11 check:
+// the first nine digits weighted from 10 down to 2 give the tenth one.
+func isNHSValid(match string) bool {
+	digits := stripSeparators(match)
+	if len(digits) != 10 || !isDigits(digits) {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(digits[i]-'0') * (10 - i)
+	}
+	check := 11 - sum%11
+	if check == 11 {
+		check = 0
+	}
+	// A check digit of 10 is never issued.
+	return check != 10 && check == int(digits[9]-'0')
+}
+
+// isCPFValid reports whether the match is a Brazilian CPF whose two last digits are the
+// mod-11 check digits of the ones before them.
+func isCPFValid(match string) bool {
+	digits := stripBrazilianSeparators(match)
+	if len(digits) != 11 || !isDigits(digits) || isRepeated(digits) {
+		return false
+	}
+
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(digits[i]-'0') * (n + 1 - i)
+		}
+		check := sum * 10 % 11
+		if check == 10 {
+			check = 0
+		}
+		if check != int(digits[n]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+// isCNPJValid reports whether the match is a Brazilian CNPJ whose two last digits are the
+// mod-11 check digits of the ones before them.
+func isCNPJValid(match string) bool {
+	digits := stripBrazilianSeparators(match)
+	if len(digits) != 14 || !isDigits(digits) || isRepeated(digits) {
+		return false
+	}
+
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	for n := 12; n <= 13; n++ {
+		sum := 0
+		// The first check digit skips the first weight.
+		for i, w := range weights[13-n:] {
+			sum += int(digits[i]-'0') * w
+		}
+		check := 0
+		if sum%11 >= 2 {
+			check = 11 - sum%11
+		}
+		if check != int(digits[n]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+// verhoeffMultiplication, verhoeffPermutation are the tables of the Verhoeff checksum.
+var (
+	verhoeffMultiplication = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPermutation = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 8, 7, 6, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// isAadhaarValid reports whether the match is an Indian Aadhaar number passing the Verhoeff checksum.
+func isAadhaarValid(match string) bool {
+	digits := stripSeparators(match)
+	if len(digits) != 12 || !isDigits(digits) || digits[0] < '2' {
+		return false
+	}
+
+	check := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		check = verhoeffMultiplication[check][verhoeffPermutation[i%8][d]]
+	}
+	return check == 0
+}
+
+// vatChecks are the checks of the VAT numbers of the member states, by country code, given the
+// digits following it. The numbers of the other member states are reported by their format only.
+var vatChecks = map[string]func(digits string) bool{
+	"AT": isATVATValid,
+	"BE": func(digits string) bool { return mod97Check(digits[:8], digits[8:]) },
+	"DE": isDEVATValid,
+	"DK": func(digits string) bool { return weightedSum(digits, 2, 7, 6, 5, 4, 3, 2, 1)%11 == 0 },
+	"EE": func(digits string) bool { return tenComplement(weightedSum(digits, 3, 7, 1, 3, 7, 1, 3, 7), digits[8]) },
+	"FI": func(digits string) bool {
+		return elevenComplement(weightedSum(digits, 7, 9, 10, 5, 8, 4, 2), digits[7], noCheck, 0)
+	},
+	"FR": isFRVATValid,
+	"HU": func(digits string) bool { return tenComplement(weightedSum(digits, 9, 7, 3, 1, 9, 7, 3), digits[7]) },
+	"IT": func(digits string) bool { return isLuhnChecksum(digits) },
+	"LU": func(digits string) bool { return atoi(digits[:6])%89 == atoi(digits[6:]) },
+	"PL": func(digits string) bool {
+		check := weightedSum(digits, 6, 5, 7, 2, 3, 4, 5, 6, 7) % 11
+		return check != 10 && check == int(digits[9]-'0')
+	},
+	"PT": func(digits string) bool {
+		return elevenComplement(weightedSum(digits, 9, 8, 7, 6, 5, 4, 3, 2), digits[8], 0, 0)
+	},
+	"SE": func(digits string) bool { return strings.HasSuffix(digits, "01") && isLuhnChecksum(digits[:10]) },
+	"SI": func(digits string) bool {
+		return digits[0] != '0' && elevenComplement(weightedSum(digits, 8, 7, 6, 5, 4, 3, 2), digits[7], 0, noCheck)
+	},
+}
+
+// isVATValid reports whether the match is an EU VAT identification number passing the check of its
+// member state, the numbers of the member states without a check being valid.
+func isVATValid(match string) bool {
+	country, number := match[:2], strings.TrimPrefix(match[2:], "U")
+	check, ok := vatChecks[country]
+	if !ok {
+		return true
+	}
+	// The French keys with letters have no check.
+	if country == "FR" && !isDigits(number[:2]) {
+		return true
+	}
+	return isDigits(number) && check(number)
+}
+
+// isATVATValid reports whether the eight digits following ATU end with their check digit.
+func isATVATValid(digits string) bool {
+	sum := 0
+	for i := 0; i < 7; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d = d/5 + d*2%10
+		}
+		sum += d
+	}
+	return tenComplement(sum+4, digits[7])
+}
+
+// isDEVATValid reports whether the nine digits end with their ISO 7064 MOD 11,10 check digit.
+func isDEVATValid(digits string) bool {
+	product := 10
+	for i := 0; i < 8; i++ {
+		sum := (int(digits[i]-'0') + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = sum * 2 % 11
+	}
+	check := 11 - product
+	if check == 10 {
+		check = 0
+	}
+	return check == int(digits[8]-'0')
+}
+
+// isFRVATValid reports whether the two digits of the key match the nine digits of the SIREN following them.
+func isFRVATValid(digits string) bool {
+	return atoi(digits[:2]) == (12+3*(atoi(digits[2:])%97))%97
+}
+
+// mod97Check reports whether the check digits are 97 minus the remainder of the number divided by 97.
+func mod97Check(number string, check string) bool {
+	return 97-atoi(number)%97 == atoi(check)
+}
+
+// weightedSum returns the sum of the first digits multiplied by the weights.
+func weightedSum(digits string, weights ...int) int {
+	sum := 0
+	for i, w := range weights {
+		sum += int(digits[i]-'0') * w
+	}
+	return sum
+}
+
+// tenComplement reports whether the check digit is the complement to ten of the last digit of the sum.
+func tenComplement(sum int, check byte) bool {
+	return (10-sum%10)%10 == int(check-'0')
+}
+
+// noCheck stands for the check digits that are never issued.
+const noCheck = -1
+
+// elevenComplement reports whether the check digit is 11 minus the remainder of the sum divided by 11,
+// the given digits standing for 10 and 11, or noCheck when the numbers giving them are not issued.
+func elevenComplement(sum int, check byte, ten int, eleven int) bool {
+	expected := 11 - sum%11
+	switch expected {
+	case 10:
+		expected = ten
+	case 11:
+		expected = eleven
+	}
+	return expected != noCheck && expected == int(check-'0')
+}
+
+// isLuhnChecksum reports whether the digits pass the Luhn checksum: doubling every second digit
+// from the right, and subtracting 9 from the doubles above 9, gives a sum that is a multiple of 10.
+func isLuhnChecksum(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// atoi returns the number made of the digits, which must fit an int.
+func atoi(digits string) int {
+	n := 0
+	for i := 0; i < len(digits); i++ {
+		n = n*10 + int(digits[i]-'0')
+	}
+	return n
+}
+
+// isDigits reports whether s only holds ASCII digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isRepeated reports whether s is the same character repeated, such as the
+// 111.111.111-11 placeholder CPF that passes its checksum.
+func isRepeated(s string) bool {
+	return strings.Count(s, s[:1]) == len(s)
+}
+
+// stripBrazilianSeparators removes the dots, slash and dash of the CPF and CNPJ formats.
+func stripBrazilianSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '/' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// stripSeparators removes the spaces and dashes used to group digits.
+func stripSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,101 @@
+package validator
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestKindChecks(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		match string
+		want  bool
+	}{
+		{KindLuhn, "4111 1111 1111 1111", true},
+		{KindLuhn, "4012888888881881", true},
+		{KindLuhn, "4111-1111-1111-1112", false},
+		{KindLuhn, "4242424242", false},
+
+		{KindIBAN, "GB82 WEST 1234 5698 7654 32", true},
+		{KindIBAN, "DE89370400440532013000", true},
+		{KindIBAN, "DE89370400440532013001", false},
+		{KindIBAN, "GB82WEST12", false},
+
+		{KindSSN, "123-45-6789", true},
+		{KindSSN, "123456789", true},
+		{KindSSN, "000-12-3456", false},
+		{KindSSN, "666-12-3456", false},
+		{KindSSN, "900-12-3456", false},
+		{KindSSN, "123-00-4567", false},
+		{KindSSN, "123-45-0000", false},
+
+		{KindNINO, "AB 12 34 56 C", true},
+		{KindNINO, "AB123456C", true},
+		{KindNINO, "BG123456C", false},
+		{KindNINO, "ZZ123456D", false},
+
+		{KindNHS, "943 476 5919", true},
+		{KindNHS, "9434765919", true},
+		{KindNHS, "943 476 5918", false},
+
+		{KindCPF, "529.982.247-25", true},
+		{KindCPF, "52998224725", true},
+		{KindCPF, "529.982.247-24", false},
+		{KindCPF, "111.111.111-11", false},
+
+		{KindCNPJ, "11.222.333/0001-81", true},
+		{KindCNPJ, "11222333000181", true},
+		{KindCNPJ, "11.222.333/0001-80", false},
+		{KindCNPJ, "00.000.000/0000-00", false},
+
+		{KindAadhaar, "2341 2341 2346", true},
+		{KindAadhaar, "234123412346", true},
+		{KindAadhaar, "2341 2341 2347", false},
+		{KindAadhaar, "1341 2341 2346", false},
+
+		{KindVAT, "ATU13585627", true},
+		{KindVAT, "ATU13585628", false},
+		{KindVAT, "BE0776091951", true},
+		{KindVAT, "BE0776091952", false},
+		{KindVAT, "DE136695976", true},
+		{KindVAT, "DE136695977", false},
+		{KindVAT, "DK13585628", true},
+		{KindVAT, "DK13585627", false},
+		{KindVAT, "EE100931558", true},
+		{KindVAT, "EE100931559", false},
+		{KindVAT, "FI20774740", true},
+		{KindVAT, "FI20774741", false},
+		{KindVAT, "FR40303265045", true},
+		{KindVAT, "FR41303265045", false},
+		{KindVAT, "FRAB303265045", true},
+		{KindVAT, "HU21376414", true},
+		{KindVAT, "HU21376415", false},
+		{KindVAT, "IT00743110157", true},
+		{KindVAT, "IT00743110158", false},
+		{KindVAT, "LU15027442", true},
+		{KindVAT, "LU15027443", false},
+		{KindVAT, "PL8567346215", true},
+		{KindVAT, "PL8567346216", false},
+		{KindVAT, "PT501964843", true},
+		{KindVAT, "PT501964844", false},
+		{KindVAT, "SE556042722001", true},
+		{KindVAT, "SE556042722101", false},
+		{KindVAT, "SI50223054", true},
+		{KindVAT, "SI50223055", false},
+		// The member states without a check are reported by their format.
+		{KindVAT, "NL123456789B01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+"/"+tt.match, func(t *testing.T) {
+			check := kindChecks[tt.kind]
+			if got := check.verify(tt.match); got != tt.want {
+				t.Errorf("%s check of %q = %t, want %t", tt.kind, tt.match, got, tt.want)
+			}
+			// The valid identifiers are found whole by the pattern of their kind.
+			if found := regexp.MustCompile(check.pattern).FindString(tt.match); tt.want && found != tt.match {
+				t.Errorf("%s pattern found %q in %q, want the whole identifier", tt.kind, found, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package validator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DetectorAll enables every built-in detector.
+const DetectorAll = "all"
+
+// entropyThreshold is the Shannon entropy, in bits per character, above which
+// a long string is considered random enough to be a secret. Hexadecimal strings,
+// such as git commit hashes, stay below 4 bits per character.
+const entropyThreshold = 4.2
+
+// detector is a built-in validation rule finding a kind of secret, or a dangerous command.
+type detector struct {
+	// The name the detector is enabled with.
+	name string
+	// The regular expression finding the candidates.
+	pattern string
+	// Whether a candidate found by the pattern is a secret, nil when they all are.
+	verify func(match string) bool
+	// A faster equivalent of the pattern's FindAllStringIndex, nil when there is none.
+	find func(input string) [][]int
+	// How harmful a match is, high when empty.
+	severity Severity
+}
+
+// detectors are the built-in validation rules, enabled by name in the validations file.
+// The generic high entropy check comes last, so that the secrets are reported by their kind.
+var detectors = []detector{
+	{
+		name:    "aws-access-key",
+		pattern: `\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`,
+	},
+	{
+		name:    "github-token",
+		pattern: `\b(?:(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`,
+	},
+	{
+		name:    "gitlab-token",
+		pattern: `\bglpat-[A-Za-z0-9_-]{20,}`,
+	},
+	{
+		name:    "slack-token",
+		pattern: `\bxox[abposr]-[A-Za-z0-9-]{10,}|https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`,
+	},
+	{
+		name:    "jwt",
+		pattern: `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+		verify:  isJWT,
+	},
+	{
+		name:    "private-key",
+		pattern: `(?s)-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----|\z)`,
+	},
+	{
+		name:    "connection-string",
+		pattern: `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:[^\s/@]+@[^\s/?#]+`,
+	},
+	{
+		name:    "high-entropy-string",
+		pattern: `[A-Za-z0-9+/=_-]{32,}`,
+		verify:  isHighEntropy,
+		find:    findTokenRuns,
+	},
+}
+
+// findDetector returns the built-in detector with the given name.
+func findDetector(name string) (detector, bool) {
+	for _, d := range detectors {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return detector{}, false
+}
+
+// DetectorNames returns the names of the built-in detectors.
+func DetectorNames() []string {
+	names := make([]string, 0, len(detectors))
+	for _, d := range detectors {
+		names = append(names, d.name)
+	}
+	return names
+}
+
+// isJWT reports whether the match is a JSON Web Token, whose header is base64url encoded JSON.
+func isJWT(match string) bool {
+	header, _, _ := strings.Cut(match, ".")
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(decoded, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["alg"]
+	return ok
+}
+
+// isHighEntropy reports whether the match mixes letters and digits, and is random enough to be a secret.
+func isHighEntropy(match string) bool {
+	hasLetter := strings.IndexFunc(match, unicode.IsLetter) >= 0
+	hasDigit := strings.IndexFunc(match, unicode.IsDigit) >= 0
+	if !hasLetter || !hasDigit {
+		return false
+	}
+	return shannonEntropy(match) >= entropyThreshold
+}
+
+// minTokenRun is the minimum length of the runs found by findTokenRuns.
+const minTokenRun = 32
+
+// findTokenRuns returns the runs of 32 or more base64 and base64url characters, as the
+// pattern [A-Za-z0-9+/=_-]{32,} of the high entropy detector does, a byte at a time.
+func findTokenRuns(input string) [][]int {
+	var spans [][]int
+	start := -1
+	for i := 0; i <= len(input); i++ {
+		if i < len(input) && isTokenByte(input[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minTokenRun {
+			spans = append(spans, []int{start, i})
+		}
+		start = -1
+	}
+	return spans
+}
+
+// isTokenByte reports whether c is a base64 or base64url character.
+func isTokenByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '=' || c == '_' || c == '-'
+}
+
+// shannonEntropy returns the Shannon entropy of the string, in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	// The ASCII characters, making up the candidates of the detectors, are counted without a map.
+	var asciiCounts [utf8.RuneSelf]int
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			asciiCounts[r]++
+		} else {
+			counts[r]++
+		}
+		total++
+	}
+
+	var entropy float64
+	add := func(count int) {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	for _, count := range asciiCounts {
+		if count > 0 {
+			add(count)
+		}
+	}
+	for _, count := range counts {
+		add(count)
+	}
+	return entropy
+}
@@ -0,0 +1,248 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultHookTimeout is how long a hook command may run when its rule sets no timeout.
+const defaultHookTimeout = 5 * time.Second
+
+// Hook is an external command validating the prompt, such as a company secret scanner.
+// The prompt is written to its stdin, and it writes a JSON verdict to its stdout:
+//
+//	{"action": "block", "findings": [{"name": "api key", "start": 10, "end": 42, "severity": "high"}]}
+//
+// The verdict's action applies to the findings that do not set one, and "allow" ignores them.
+// A block or warn verdict without findings is reported for the whole prompt.
+type Hook struct {
+	Name string `mapstructure:"name"`
+	// The program to run followed by its arguments. No shell is involved.
+	Command []string `mapstructure:"command"`
+	// How long the command may run (e.g. "2s"). Defaults to 5 seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Whether the prompt is sent when the command fails, times out or writes an invalid verdict.
+	// By default the prompt is blocked.
+	FailOpen bool `mapstructure:"fail_open"`
+	// The action of the findings when neither the verdict nor the finding sets one. Defaults to the file's mode.
+	Action Action `mapstructure:"action"`
+	// The severity of the findings that do not set one. Defaults to high.
+	Severity Severity `mapstructure:"severity"`
+	// Whether the hook is mandatory: the later layers cannot replace it, and its findings cannot be allowed.
+	Locked bool `mapstructure:"locked"`
+	// The layer and file the hook comes from.
+	Source string `mapstructure:"-"`
+}
+
+// verdict is the JSON output of a hook command.
+type verdict struct {
+	Action   string           `json:"action"`
+	Findings []verdictFinding `json:"findings"`
+}
+
+// verdictFinding is a finding reported by a hook command, spanning the byte offsets of the prompt.
+type verdictFinding struct {
+	Name     string   `json:"name"`
+	Action   Action   `json:"action"`
+	Severity Severity `json:"severity"`
+	Start    int      `json:"start"`
+	End      int      `json:"end"`
+}
+
+// verdictAllow is the verdict action of a prompt the hook has nothing against.
+const verdictAllow = "allow"
+
+// checkHooks sets the defaults of the hooks and checks their settings.
+func checkHooks(hooks []Hook, mode Action) error {
+	for i, hook := range hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("hook %d has no name", i+1)
+		}
+		if len(hook.Command) == 0 {
+			return fmt.Errorf("hook '%s' has no command", hook.Name)
+		}
+		if hook.Timeout <= 0 {
+			hooks[i].Timeout = defaultHookTimeout
+		}
+
+		if hook.Action == "" {
+			hooks[i].Action = mode
+		} else if !isAction(hook.Action) {
+			return fmt.Errorf("hook '%s' has the unknown action '%s', expected one of: %s, %s, %s", hook.Name, hook.Action, ActionBlock, ActionWarn, ActionRedact)
+		}
+
+		if hook.Severity == "" {
+			hooks[i].Severity = SeverityHigh
+		} else if !isSeverity(hook.Severity) {
+			return fmt.Errorf("hook '%s' has the unknown severity '%s', expected one of: %s, %s, %s", hook.Name, hook.Severity, SeverityLow, SeverityMedium, SeverityHigh)
+		}
+	}
+	return nil
+}
+
+// runHooks runs the hook commands on the user input and returns their findings.
+// The findings of the last input are kept, so that validating the same prompt again, as the
+// models do before sending it, does not run the commands twice. They are not kept when a hook
+// failed, so that the commands run again for the same prompt.
+func (v *Validator) runHooks(userInput string) []Finding {
+	if len(v.Hooks) == 0 {
+		return nil
+	}
+
+	v.hookMu.Lock()
+	defer v.hookMu.Unlock()
+
+	if v.lastHookInput != nil && *v.lastHookInput == userInput {
+		return v.lastHookFindings
+	}
+
+	var findings []Finding
+	hasFailed := false
+	for _, hook := range v.Hooks {
+		hookFindings, err := hook.run(userInput)
+		if err != nil {
+			hasFailed = true
+			if hook.FailOpen {
+				v.Logger.Warn(
+					"Validation hook failed, the prompt is sent anyway.",
+					slog.String("package", logPackageName),
+					slog.String("hook", hook.Name),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+
+			v.Logger.Error(
+				"Validation hook failed, the prompt is blocked.",
+				slog.String("package", logPackageName),
+				slog.String("hook", hook.Name),
+				slog.String("error", err.Error()),
+			)
+			findings = append(findings, Finding{
+				Name:     fmt.Sprintf("%s (hook failed)", hook.Name),
+				Action:   ActionBlock,
+				Severity: SeverityHigh,
+				Locked:   hook.Locked,
+			})
+			continue
+		}
+		findings = append(findings, hookFindings...)
+	}
+
+	if hasFailed {
+		v.lastHookInput = nil
+		v.lastHookFindings = nil
+	} else {
+		v.lastHookInput = &userInput
+		v.lastHookFindings = findings
+	}
+
+	v.mu.Lock()
+	for _, finding := range findings {
+		if finding.Action == ActionRedact {
+			v.hookRedactions[finding.Value] = finding.Name
+		}
+	}
+	v.mu.Unlock()
+
+	return findings
+}
+
+// run pipes the user input to the hook command and returns the findings of its verdict.
+func (hook Hook) run(userInput string) ([]Finding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = strings.NewReader(userInput)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait for the children of a killed command still holding its output open.
+	cmd.WaitDelay = time.Second
+
+	// Scanners often exit with a non-zero status when they find something,
+	// so the verdict is read whatever the exit status.
+	runErr := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+
+	var verdict verdict
+	if err := json.Unmarshal(stdout.Bytes(), &verdict); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("%w: %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("invalid verdict: %w", err)
+	}
+
+	return hook.findings(verdict, userInput)
+}
+
+// findings turns the verdict of the hook into findings, checking their spans and settings.
+func (hook Hook) findings(verdict verdict, userInput string) ([]Finding, error) {
+	if verdict.Action == verdictAllow || (verdict.Action == "" && len(verdict.Findings) == 0) {
+		return nil, nil
+	}
+
+	defaultAction := Action(verdict.Action)
+	if defaultAction == "" {
+		defaultAction = hook.Action
+	}
+	if !isAction(defaultAction) {
+		return nil, fmt.Errorf("invalid verdict: unknown action '%s', expected one of: %s, %s, %s, %s", verdict.Action, verdictAllow, ActionBlock, ActionWarn, ActionRedact)
+	}
+
+	if len(verdict.Findings) == 0 {
+		if defaultAction == ActionRedact {
+			return nil, errors.New("invalid verdict: the redact action needs findings to redact")
+		}
+		return []Finding{{Name: hook.Name, Action: defaultAction, Severity: hook.Severity, Locked: hook.Locked}}, nil
+	}
+
+	findings := make([]Finding, 0, len(verdict.Findings))
+	for _, f := range verdict.Findings {
+		finding := Finding{
+			Name:     f.Name,
+			Action:   f.Action,
+			Severity: f.Severity,
+			Start:    f.Start,
+			End:      f.End,
+			Locked:   hook.Locked,
+		}
+		if finding.Name == "" {
+			finding.Name = hook.Name
+		}
+		if finding.Action == "" {
+			finding.Action = defaultAction
+		}
+		if finding.Severity == "" {
+			finding.Severity = hook.Severity
+		}
+
+		if !isAction(finding.Action) {
+			return nil, fmt.Errorf("invalid verdict: finding '%s' has the unknown action '%s'", finding.Name, finding.Action)
+		}
+		if !isSeverity(finding.Severity) {
+			return nil, fmt.Errorf("invalid verdict: finding '%s' has the unknown severity '%s'", finding.Name, finding.Severity)
+		}
+		if finding.Start < 0 || finding.End > len(userInput) || finding.Start > finding.End {
+			return nil, fmt.Errorf("invalid verdict: finding '%s' spans [%d, %d) out of the %d bytes of the prompt", finding.Name, finding.Start, finding.End, len(userInput))
+		}
+		if finding.Action == ActionRedact && finding.Start == finding.End {
+			return nil, fmt.Errorf("invalid verdict: finding '%s' has the redact action but spans nothing", finding.Name)
+		}
+
+		finding.Value = userInput[finding.Start:finding.End]
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
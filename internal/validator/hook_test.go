@@ -0,0 +1,57 @@
+package validator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestParallelValidationsRunTheChecksOnce checks that the models validating the same prompt
+// at once, as in compare mode, run the hook and the moderation check a single time.
+// Run with -race to also check the access to the shared state of the validator.
+func TestParallelValidationsRunTheChecksOnce(t *testing.T) {
+	dir := t.TempDir()
+	hookRuns := filepath.Join(dir, "hook_runs")
+	moderationRuns := filepath.Join(dir, "moderation_runs")
+
+	v := newFileValidator(t, fmt.Sprintf(`
+mode = "block"
+
+[[validation]]
+name = "email"
+pattern = '''[a-z]+@example\.com'''
+action = "redact"
+
+[[hook]]
+name = "scanner"
+command = ["sh", "-c", '''cat > /dev/null; echo run >> %s; sleep 0.2; echo '{"action": "allow"}' ''']
+
+[moderation]
+provider = "command"
+command = ["sh", "-c", '''cat > /dev/null; echo run >> %s; sleep 0.2; echo '{"flagged": false}' ''']
+`, hookRuns, moderationRuns))
+
+	const models = 8
+	var wg sync.WaitGroup
+	for range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.Validate("mail bob@example.com the logs")
+		}()
+	}
+	wg.Wait()
+
+	for _, path := range []string{hookRuns, moderationRuns} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs := strings.Count(string(b), "run"); runs != 1 {
+			t.Errorf("%s: ran %d times for %d parallel validations of the same prompt, want 1", filepath.Base(path), runs, models)
+		}
+	}
+}
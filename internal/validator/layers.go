@@ -0,0 +1,291 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/nycruz/gail/internal/config"
+	"github.com/spf13/viper"
+)
+
+// SystemValidationsDir holds the validation files shipped by the system administrators,
+// such as the mandatory rules of a security team. Its '*.toml' files are read in name order.
+const SystemValidationsDir = "/etc/gail/validations.d"
+
+// Layer is where a validation file comes from. The layers are merged in the order
+// system, user, project: a rule of the user layer replaces the rule of the same name
+// of the system layer, unless that rule is locked. The project file comes from the
+// directory Gail runs in, which may not be trusted, so it can only add rules: its hooks,
+// moderation check and rules named like a rule of an earlier layer are ignored.
+type Layer string
+
+const (
+	LayerSystem  Layer = "system"
+	LayerUser    Layer = "user"
+	LayerProject Layer = "project"
+)
+
+// policyFile is a validation file of a layer.
+type policyFile struct {
+	layer Layer
+	path  string
+}
+
+// source describes where the rules of the file come from, e.g. "user: ~/.config/gail/validations.toml".
+func (f policyFile) source() string {
+	return fmt.Sprintf("%s: %s", f.layer, f.path)
+}
+
+// policyFiles returns the validation files of all the layers, in merge order.
+// The user file is required, the system and project ones are optional.
+func policyFiles(validationsFilename string, configDirPath string) ([]policyFile, error) {
+	var files []policyFile
+
+	systemPaths, err := filepath.Glob(filepath.Join(SystemValidationsDir, "*.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the system validation files: %w", err)
+	}
+	sort.Strings(systemPaths)
+	for _, path := range systemPaths {
+		files = append(files, policyFile{layer: LayerSystem, path: path})
+	}
+
+	files = append(files, policyFile{layer: LayerUser, path: filepath.Join(configDirPath, validationsFilename+".toml")})
+
+	projectPath, err := config.FindProjectFile(validationsFilename + ".toml")
+	if err != nil {
+		return nil, err
+	}
+	if projectPath != "" {
+		files = append(files, policyFile{layer: LayerProject, path: projectPath})
+	}
+
+	return files, nil
+}
+
+// policy holds the rules and hooks read from the validation files.
+type policy struct {
+	// The mode of the user file.
+	mode        Action
+	validations []Validation
+	hooks       []Hook
+	// The rules checking the answers of the models.
+	responseValidations []Validation
+	moderation          *Moderation
+}
+
+// readPolicyFile reads the rules and hooks of the validation file, with their defaults set
+// from the file's mode, and returns them along with the mode.
+func readPolicyFile(file policyFile) (policy, error) {
+	if _, err := os.Stat(file.path); err != nil {
+		return policy{}, fmt.Errorf("failed to read the '%s' config file: %w", file.path, err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file.path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return policy{}, fmt.Errorf("failed to read the '%s' config file: %w", file.path, err)
+	}
+
+	var vc ValidateConfig
+	if err := v.Unmarshal(&vc); err != nil {
+		return policy{}, fmt.Errorf("failed to unmarshal the '%s' config file: %w", file.path, err)
+	}
+
+	if vc.Mode == "" {
+		vc.Mode = ActionBlock
+	}
+	if !isAction(vc.Mode) {
+		return policy{}, fmt.Errorf("invalid mode '%s' in the '%s' config file: expected '%s', '%s' or '%s'", vc.Mode, file.path, ActionBlock, ActionWarn, ActionRedact)
+	}
+
+	if err := applyKinds(vc.Validations); err != nil {
+		return policy{}, fmt.Errorf("invalid validation in the '%s' config file: %w", file.path, err)
+	}
+
+	validations, err := withDetectors(vc.Validations, vc.Detectors)
+	if err != nil {
+		return policy{}, fmt.Errorf("invalid detectors in the '%s' config file: %w", file.path, err)
+	}
+
+	validations, err = withPacks(validations, vc.Packs)
+	if err != nil {
+		return policy{}, fmt.Errorf("invalid packs in the '%s' config file: %w", file.path, err)
+	}
+
+	if err := applyDefaults(validations, vc.Mode); err != nil {
+		return policy{}, fmt.Errorf("invalid validation in the '%s' config file: %w", file.path, err)
+	}
+
+	if err := compile(validations); err != nil {
+		return policy{}, fmt.Errorf("invalid validation rules in the '%s' config file:\n%w", file.path, err)
+	}
+
+	if err := checkHooks(vc.Hooks, vc.Mode); err != nil {
+		return policy{}, fmt.Errorf("invalid hook in the '%s' config file: %w", file.path, err)
+	}
+
+	responseValidations, err := responseRules(vc.Response)
+	if err != nil {
+		return policy{}, fmt.Errorf("invalid response check in the '%s' config file: %w", file.path, err)
+	}
+
+	if vc.Moderation != nil {
+		if err := checkModeration(vc.Moderation, vc.Mode); err != nil {
+			return policy{}, fmt.Errorf("invalid moderation check in the '%s' config file: %w", file.path, err)
+		}
+		vc.Moderation.Source = file.source()
+	}
+
+	for i := range validations {
+		validations[i].Source = file.source()
+	}
+	for i := range vc.Hooks {
+		vc.Hooks[i].Source = file.source()
+	}
+	for i := range responseValidations {
+		responseValidations[i].Source = file.source()
+	}
+
+	return policy{
+		mode:                vc.Mode,
+		validations:         validations,
+		hooks:               vc.Hooks,
+		responseValidations: responseValidations,
+		moderation:          vc.Moderation,
+	}, nil
+}
+
+// readLayers reads and merges the validation files of all the layers. The mode is the one of the user file.
+func readLayers(logger *slog.Logger, validationsFilename string, configDirPath string) (policy, error) {
+	files, err := policyFiles(validationsFilename, configDirPath)
+	if err != nil {
+		return policy{}, err
+	}
+
+	merged := policy{mode: ActionBlock}
+	var errs []error
+	for _, file := range files {
+		p, err := readPolicyFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if file.layer == LayerUser {
+			merged.mode = p.mode
+		}
+
+		if file.layer == LayerProject {
+			merged = mergeProject(logger, merged, p)
+			continue
+		}
+
+		validationKey := func(v Validation) (string, bool) { return v.Name, v.Locked }
+		for _, validation := range p.validations {
+			var ok bool
+			if merged.validations, ok = merge(merged.validations, validation, validationKey); !ok {
+				warnLocked(logger, "validation", validation.Name, validation.Source)
+			}
+		}
+		for _, hook := range p.hooks {
+			var ok bool
+			if merged.hooks, ok = merge(merged.hooks, hook, func(h Hook) (string, bool) { return h.Name, h.Locked }); !ok {
+				warnLocked(logger, "hook", hook.Name, hook.Source)
+			}
+		}
+		for _, validation := range p.responseValidations {
+			var ok bool
+			if merged.responseValidations, ok = merge(merged.responseValidations, validation, validationKey); !ok {
+				warnLocked(logger, "response check", validation.Name, validation.Source)
+			}
+		}
+		if p.moderation != nil {
+			if merged.moderation != nil && merged.moderation.Locked {
+				warnLocked(logger, "moderation check", p.moderation.Provider, p.moderation.Source)
+			} else {
+				merged.moderation = p.moderation
+			}
+		}
+	}
+
+	return merged, errors.Join(errs...)
+}
+
+// mergeProject adds the rules and response checks of the project file to the merged ones.
+// The rules named like a merged one are ignored, so that the project cannot disable them, and
+// so are the hooks and the moderation check, which would run commands from the project.
+func mergeProject(logger *slog.Logger, merged policy, p policy) policy {
+	isMerged := func(validations []Validation, name string) bool {
+		return slices.ContainsFunc(validations, func(v Validation) bool { return v.Name == name })
+	}
+
+	for _, validation := range p.validations {
+		if isMerged(merged.validations, validation.Name) {
+			warnProject(logger, "validation", validation.Name, validation.Source)
+			continue
+		}
+		merged.validations = append(merged.validations, validation)
+	}
+	for _, validation := range p.responseValidations {
+		if isMerged(merged.responseValidations, validation.Name) {
+			warnProject(logger, "response check", validation.Name, validation.Source)
+			continue
+		}
+		merged.responseValidations = append(merged.responseValidations, validation)
+	}
+	for _, hook := range p.hooks {
+		warnProject(logger, "hook", hook.Name, hook.Source)
+	}
+	if p.moderation != nil {
+		warnProject(logger, "moderation check", p.moderation.Provider, p.moderation.Source)
+	}
+
+	return merged
+}
+
+// merge adds the item to the merged ones, replacing the one of the same name in place,
+// and reports whether it did. A locked item is never replaced.
+func merge[T any](merged []T, item T, key func(T) (string, bool)) ([]T, bool) {
+	name, _ := key(item)
+	for i, existing := range merged {
+		existingName, isLocked := key(existing)
+		if existingName != name {
+			continue
+		}
+		if isLocked {
+			return merged, false
+		}
+		merged[i] = item
+		return merged, true
+	}
+
+	return append(merged, item), true
+}
+
+// warnProject logs that a rule of the project file is ignored, as it would replace a rule or run a command.
+func warnProject(logger *slog.Logger, kind string, name string, source string) {
+	logger.Warn(
+		"Ignored a rule of the project validation file: it can only add rules.",
+		slog.String("package", logPackageName),
+		slog.String("kind", kind),
+		slog.String("name", name),
+		slog.String("source", source),
+	)
+}
+
+// warnLocked logs that a rule overriding a locked one is ignored.
+func warnLocked(logger *slog.Logger, kind string, name string, source string) {
+	logger.Warn(
+		"Ignored a rule overriding a locked one.",
+		slog.String("package", logPackageName),
+		slog.String("kind", kind),
+		slog.String("name", name),
+		slog.String("source", source),
+	)
+}
@@ -0,0 +1,320 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nycruz/gail/internal/config"
+	"github.com/nycruz/gail/internal/models"
+)
+
+const (
+	// ModerationOpenAI runs the moderation check with the OpenAI moderation endpoint.
+	ModerationOpenAI = "openai"
+	// ModerationCommand runs the moderation check with a local classifier command.
+	ModerationCommand = "command"
+
+	// defaultModerationModel is the OpenAI moderation model used when the check sets none.
+	defaultModerationModel = "omni-moderation-latest"
+	// defaultModerationTimeout is how long the moderation check may take when it sets no timeout.
+	defaultModerationTimeout = 10 * time.Second
+
+	moderationURL = "https://api.openai.com/v1/moderations"
+)
+
+// Moderation is the moderation check some teams must run on the prompts before sending them,
+// with the OpenAI moderation endpoint or a local classifier command. The categories it flags
+// are reported as findings of the whole prompt, going through the same decisions as the rules.
+// The check runs on the prompt as it is sent, with the values to redact replaced by their
+// placeholders, and only when no rule or hook blocks it.
+type Moderation struct {
+	// Where the check runs: "openai" or "command".
+	Provider string `mapstructure:"provider"`
+	// The OpenAI moderation model. Defaults to omni-moderation-latest.
+	Model string `mapstructure:"model"`
+	// The local classifier followed by its arguments. No shell is involved. The prompt is written
+	// to its stdin, and it writes a result like the ones of the OpenAI endpoint to its stdout:
+	//
+	//	{"flagged": true, "categories": {"violence": true, "hate": false}}
+	Command []string `mapstructure:"command"`
+	// How long the check may take (e.g. "3s"). Defaults to 10 seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Whether the prompt is sent when the check fails. By default the prompt is blocked.
+	FailOpen bool `mapstructure:"fail_open"`
+	// The categories reported, all the flagged ones when empty.
+	Categories []string `mapstructure:"categories"`
+	// The action of the findings: "block" or "warn". Defaults to the file's mode, or to block when
+	// the mode is redact, as the findings are of the whole prompt and have no value to redact.
+	Action Action `mapstructure:"action"`
+	// The severity of the findings. Defaults to high.
+	Severity Severity `mapstructure:"severity"`
+	// Whether the check also runs when all the models of the session are local (e.g. mock),
+	// and the prompts never leave the machine. By default it is skipped.
+	Local bool `mapstructure:"local"`
+	// Whether the check is mandatory: the later layers cannot replace it, and its findings cannot be allowed.
+	Locked bool `mapstructure:"locked"`
+	// The layer and file the check comes from.
+	Source string `mapstructure:"-"`
+}
+
+// moderationResult is the result of the moderation of a prompt.
+type moderationResult struct {
+	Flagged    bool            `json:"flagged"`
+	Categories map[string]bool `json:"categories"`
+}
+
+// moderationRequest is the body of a request to the OpenAI moderation endpoint.
+type moderationRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// moderationResponse is the body of a response of the OpenAI moderation endpoint.
+type moderationResponse struct {
+	Results []moderationResult `json:"results"`
+}
+
+// checkModeration sets the defaults of the moderation check and checks its settings.
+func checkModeration(m *Moderation, mode Action) error {
+	switch m.Provider {
+	case ModerationOpenAI:
+		if m.Model == "" {
+			m.Model = defaultModerationModel
+		}
+	case ModerationCommand:
+		if len(m.Command) == 0 {
+			return errors.New("the moderation check has no command")
+		}
+	default:
+		return fmt.Errorf("unknown moderation provider '%s', expected '%s' or '%s'", m.Provider, ModerationOpenAI, ModerationCommand)
+	}
+
+	if m.Timeout <= 0 {
+		m.Timeout = defaultModerationTimeout
+	}
+
+	if m.Action == "" && mode == ActionRedact {
+		m.Action = ActionBlock
+	} else if m.Action == "" {
+		m.Action = mode
+	} else if m.Action != ActionBlock && m.Action != ActionWarn {
+		return fmt.Errorf("the moderation check has the action '%s', expected '%s' or '%s'", m.Action, ActionBlock, ActionWarn)
+	}
+
+	if m.Severity == "" {
+		m.Severity = SeverityHigh
+	} else if !isSeverity(m.Severity) {
+		return fmt.Errorf("the moderation check has the unknown severity '%s', expected one of: %s, %s, %s", m.Severity, SeverityLow, SeverityMedium, SeverityHigh)
+	}
+
+	return nil
+}
+
+// SetLocalOnly tells the validator that all the models of the session are local, so that
+// the moderation check is skipped unless it sets 'local'.
+func (v *Validator) SetLocalOnly() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.Moderation != nil && !v.Moderation.Local {
+		v.Logger.Info(
+			"Skipping the moderation check, as all the models are local.",
+			slog.String("package", logPackageName),
+		)
+		v.Moderation = nil
+	}
+}
+
+// runModeration runs the moderation check on the user input, with the values to redact already
+// replaced by their placeholders, as the prompt is sent, and returns its findings.
+// Like the ones of the hooks, the findings of the last input are kept, unless the check failed.
+func (v *Validator) runModeration(userInput string) []Finding {
+	v.mu.Lock()
+	moderation := v.Moderation
+	v.mu.Unlock()
+	if moderation == nil {
+		return nil
+	}
+
+	v.moderationMu.Lock()
+	defer v.moderationMu.Unlock()
+
+	if v.lastModeratedInput != nil && *v.lastModeratedInput == userInput {
+		return v.lastModerationFindings
+	}
+
+	redacted, _ := v.Redact(userInput)
+	findings, hasFailed := v.moderate(moderation, redacted)
+
+	if hasFailed {
+		v.lastModeratedInput = nil
+		v.lastModerationFindings = nil
+	} else {
+		v.lastModeratedInput = &userInput
+		v.lastModerationFindings = findings
+	}
+
+	return findings
+}
+
+// moderate runs the moderation check on the user input and returns its findings,
+// and whether the check failed.
+func (v *Validator) moderate(m *Moderation, userInput string) ([]Finding, bool) {
+	findings, err := m.run(userInput)
+	if err == nil {
+		return findings, false
+	}
+
+	if m.FailOpen {
+		v.Logger.Warn(
+			"Moderation check failed, the prompt is sent anyway.",
+			slog.String("package", logPackageName),
+			slog.String("provider", m.Provider),
+			slog.String("error", err.Error()),
+		)
+		return nil, true
+	}
+
+	v.Logger.Error(
+		"Moderation check failed, the prompt is blocked.",
+		slog.String("package", logPackageName),
+		slog.String("provider", m.Provider),
+		slog.String("error", err.Error()),
+	)
+	return []Finding{{
+		Name:     "moderation (check failed)",
+		Action:   ActionBlock,
+		Severity: SeverityHigh,
+		Locked:   m.Locked,
+	}}, true
+}
+
+// run moderates the user input and returns a finding per flagged category.
+func (m *Moderation) run(userInput string) ([]Finding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	var result moderationResult
+	var err error
+	if m.Provider == ModerationCommand {
+		result, err = m.runCommand(ctx, userInput)
+	} else {
+		result, err = m.runOpenAI(ctx, userInput)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s", m.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m.findings(result), nil
+}
+
+// runOpenAI sends the user input to the OpenAI moderation endpoint.
+func (m *Moderation) runOpenAI(ctx context.Context, userInput string) (moderationResult, error) {
+	apiKey := os.Getenv(config.EnvOpenAIAPIKey)
+	if apiKey == "" {
+		return moderationResult{}, fmt.Errorf("environment variable '%s' not set", config.EnvOpenAIAPIKey)
+	}
+
+	reqBody, err := json.Marshal(moderationRequest{Model: m.Model, Input: userInput})
+	if err != nil {
+		return moderationResult{}, fmt.Errorf("unable to json marshal the request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, moderationURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return moderationResult{}, fmt.Errorf("unable to create the http request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return moderationResult{}, fmt.Errorf("unable to make the http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return moderationResult{}, models.NewOpenAIError(resp)
+	}
+
+	var mr moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mr); err != nil {
+		return moderationResult{}, fmt.Errorf("unable to decode the moderation response: %w", err)
+	}
+	if len(mr.Results) == 0 {
+		return moderationResult{}, errors.New("the moderation response has no result")
+	}
+
+	return mr.Results[0], nil
+}
+
+// runCommand pipes the user input to the local classifier and reads its result.
+func (m *Moderation) runCommand(ctx context.Context, userInput string) (moderationResult, error) {
+	cmd := exec.CommandContext(ctx, m.Command[0], m.Command[1:]...)
+	cmd.Stdin = strings.NewReader(userInput)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait for the children of a killed command still holding its output open.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		return moderationResult{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result moderationResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return moderationResult{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	return result, nil
+}
+
+// findings returns a finding of the whole prompt per flagged category, in name order.
+// A prompt flagged without any category is reported as such.
+func (m *Moderation) findings(result moderationResult) []Finding {
+	if !result.Flagged {
+		return nil
+	}
+
+	var categories []string
+	for category, isFlagged := range result.Categories {
+		if isFlagged && (len(m.Categories) == 0 || slices.Contains(m.Categories, category)) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	if len(categories) == 0 {
+		if len(m.Categories) > 0 && len(result.Categories) > 0 {
+			// Only categories not reported were flagged.
+			return nil
+		}
+		return []Finding{{Name: "moderation", Action: m.Action, Severity: m.Severity, Locked: m.Locked}}
+	}
+
+	findings := make([]Finding, 0, len(categories))
+	for _, category := range categories {
+		findings = append(findings, Finding{
+			Name:     fmt.Sprintf("moderation: %s", category),
+			Action:   m.Action,
+			Severity: m.Severity,
+			Locked:   m.Locked,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,44 @@
+package validator
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newFileValidator returns a validator reading the given content as the user validation file.
+func newFileValidator(t *testing.T, content string) *Validator {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "validations.toml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), "validations", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestModerationBlocksInRedactMode(t *testing.T) {
+	v := newFileValidator(t, `
+mode = "redact"
+
+[moderation]
+provider = "command"
+command = ["sh", "-c", '''cat > /dev/null; echo '{"flagged": true, "categories": {"violence": true}}' ''']
+`)
+
+	if v.Moderation.Action != ActionBlock {
+		t.Errorf("the moderation action is '%s', want '%s'", v.Moderation.Action, ActionBlock)
+	}
+
+	findings := v.Validate("a flagged prompt")
+	if _, isBlocked := BlockingMessage(findings); !isBlocked {
+		t.Errorf("the flagged prompt is not blocked, findings: %v", findings)
+	}
+}
@@ -0,0 +1,78 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// packs are the built-in regional rule packs, enabled by name in the validations file.
+// The rules use the checksum of their kind when the identifier has one.
+var packs = map[string][]Validation{
+	"eu": {
+		{
+			Name: "eu-iban",
+			// The IBANs of the member states, whose country codes the scanner looks for first.
+			Kind:    KindIBAN,
+			Pattern: `\b(?:AT|BE|BG|CY|CZ|DE|DK|EE|ES|FI|FR|GR|HR|HU|IE|IT|LT|LU|LV|MT|NL|PL|PT|RO|SE|SI|SK)\d{2}(?: ?[A-Z0-9]){11,30}\b`,
+		},
+		{Name: "eu-vat-number", Kind: KindVAT},
+	},
+	"uk": {
+		{Name: "uk-national-insurance-number", Kind: KindNINO},
+		{Name: "uk-nhs-number", Kind: KindNHS},
+	},
+	"br": {
+		{Name: "br-cpf", Kind: KindCPF},
+		{Name: "br-cnpj", Kind: KindCNPJ},
+	},
+	"in": {
+		{Name: "in-aadhaar", Kind: KindAadhaar},
+		{
+			Name: "in-pan",
+			// Five letters, the fourth one being the holder's type, four digits and a letter.
+			Pattern: `\b[A-Z]{3}[ABCFGHJLPT][A-Z]\d{4}[A-Z]\b`,
+		},
+	},
+	"e164": {
+		{
+			Name: "e164-phone-number",
+			// A plus sign, a country code not starting with 0, and up to 15 digits in all.
+			Pattern:  `\+[1-9]\d{7,14}\b`,
+			Severity: SeverityMedium,
+		},
+	},
+}
+
+// PackNames returns the names of the built-in rule packs, in alphabetical order.
+func PackNames() []string {
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPacks appends the rules of the packs enabled by name to the validation rules.
+// The rules of the packs having a kind get its check and default pattern.
+func withPacks(validations []Validation, names []string) ([]Validation, error) {
+	for _, name := range names {
+		pack, ok := packs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pack '%s', expected one of: %s", name, strings.Join(PackNames(), ", "))
+		}
+
+		rules := make([]Validation, len(pack))
+		copy(rules, pack)
+		if err := applyKinds(rules); err != nil {
+			return nil, err
+		}
+		for i := range rules {
+			rules[i].Pack = name
+		}
+		validations = append(validations, rules...)
+	}
+
+	return validations, nil
+}
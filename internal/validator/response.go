@@ -0,0 +1,188 @@
+package validator
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// CommandAll enables every built-in rule of dangerous commands.
+const CommandAll = "all"
+
+// ResponseConfig holds the checks of the answers of the models. The answers are never
+// blocked nor changed: the lines matching the rules are flagged before anyone copies them.
+type ResponseConfig struct {
+	// The names of the built-in rules of dangerous commands to enable, or "all".
+	Commands []string `mapstructure:"commands"`
+	// The names of the built-in detectors finding the secrets echoed back to enable, or "all".
+	Detectors   []string     `mapstructure:"detectors"`
+	Validations []Validation `mapstructure:"validation"`
+}
+
+// commands are the built-in rules flagging the destructive shell commands and queries
+// in the answers, enabled by name in the response section of the validations file.
+var commands = []detector{
+	{
+		name: "rm-rf-root",
+		// A recursive rm of the root, home or current directory, whatever the other options.
+		pattern: `(?m)\brm[ \t]+(?:-\S+[ \t]+)*-(?:[a-zA-Z]*[rR][a-zA-Z]*|-recursive)[ \t]+(?:-\S+[ \t]+)*(?:/\*?|~/?|\$HOME/?|\*)(?:[ \t;&|]|$)`,
+	},
+	{
+		name:    "kubectl-delete",
+		pattern: `\bkubectl[ \t]+(?:\S+[ \t]+)*?delete[ \t]+(?:-\S+[ \t]+)*(?:ns|namespaces?|nodes?|pv|persistentvolumes?|crds?|customresourcedefinitions?)\b|\bkubectl[ \t]+(?:\S+[ \t]+)*?delete[ \t][^\n]*--all\b`,
+	},
+	{
+		name:    "sql-drop",
+		pattern: `(?i)\b(?:drop[ \t]+(?:table|database|schema)|truncate[ \t]+table)\b`,
+	},
+	{
+		name: "sql-delete-all",
+		// A DELETE statement ending without a WHERE clause.
+		pattern: "(?i)\\bdelete[ \\t]+from[ \\t]+[\\w.\"`]+[ \\t]*;",
+	},
+	{
+		name:     "git-force-push",
+		pattern:  `\bgit[ \t]+push\b[^\n;&|]*[ \t](?:--force\b|-f\b|\+\S)`,
+		severity: SeverityMedium,
+	},
+	{
+		name:    "terraform-destroy",
+		pattern: `\bterraform[ \t]+(?:-\S+[ \t]+)*destroy\b|\bterraform[ \t]+apply\b[^\n]*[ \t]-destroy\b`,
+	},
+	{
+		name:    "disk-wipe",
+		pattern: `\b(?:mkfs(?:\.\w+)?[ \t]+(?:-\S+[ \t]+)*/dev/|dd[ \t][^\n]*\bof=/dev/(?:sd|hd|vd|xvd|nvme|disk|mmcblk)|wipefs[ \t])`,
+	},
+	{
+		name:    "fork-bomb",
+		pattern: `:\(\)[ \t]*\{[ \t]*:[ \t]*\|[ \t]*:[ \t]*&[ \t]*\}[ \t]*;[ \t]*:`,
+	},
+	{
+		name:     "curl-pipe-shell",
+		pattern:  `\b(?:curl|wget)\b[^\n|]*\|[ \t]*(?:sudo[ \t]+)?(?:ba|z|da)?sh\b`,
+		severity: SeverityMedium,
+	},
+}
+
+// findCommand returns the built-in rule of dangerous commands with the given name.
+func findCommand(name string) (detector, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return detector{}, false
+}
+
+// CommandNames returns the names of the built-in rules of dangerous commands.
+func CommandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// withCommands appends the built-in rules of dangerous commands enabled by name to the validation rules.
+func withCommands(validations []Validation, names []string) ([]Validation, error) {
+	for _, name := range names {
+		if name == CommandAll {
+			names = CommandNames()
+			break
+		}
+	}
+
+	for _, name := range names {
+		c, ok := findCommand(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown command rule '%s', expected one of: %s", name, strings.Join(CommandNames(), ", "))
+		}
+		validations = append(validations, Validation{
+			Name:     c.name,
+			Pattern:  c.pattern,
+			Severity: c.severity,
+			Builtin:  true,
+		})
+	}
+
+	return validations, nil
+}
+
+// responseRules returns the compiled rules checking the answers: the rules of the response
+// section of a validations file, and the built-in rules and detectors it enables.
+func responseRules(rc ResponseConfig) ([]Validation, error) {
+	if err := applyKinds(rc.Validations); err != nil {
+		return nil, err
+	}
+	for _, validation := range rc.Validations {
+		if validation.Action != "" && validation.Action != ActionWarn {
+			return nil, fmt.Errorf("validation '%s' has the action '%s', the answers can only be flagged with '%s'", validation.Name, validation.Action, ActionWarn)
+		}
+	}
+
+	validations, err := withCommands(rc.Validations, rc.Commands)
+	if err != nil {
+		return nil, err
+	}
+
+	validations, err = withDetectors(validations, rc.Detectors)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyDefaults(validations, ActionWarn); err != nil {
+		return nil, err
+	}
+
+	if err := compile(validations); err != nil {
+		return nil, err
+	}
+
+	return validations, nil
+}
+
+// CheckResponse returns the matches of the response rules in the answer of a model, in order
+// of appearance. The allowlists apply to them as they do to the prompts.
+func (v *Validator) CheckResponse(answer string) []Finding {
+	if len(v.ResponseValidations) == 0 {
+		return nil
+	}
+
+	spans := v.responseScanner.scan(v.ResponseValidations, answer)
+
+	v.mu.Lock()
+	var findings []Finding
+	for i, validation := range v.ResponseValidations {
+		for _, span := range spans[i] {
+			if v.isAllowed(validation, answer[span[0]:span[1]]) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Name:     validation.Name,
+				Action:   validation.Action,
+				Severity: validation.Severity,
+				Start:    span[0],
+				End:      span[1],
+				Value:    answer[span[0]:span[1]],
+				Locked:   validation.Locked,
+			})
+		}
+	}
+	v.mu.Unlock()
+
+	for _, finding := range findings {
+		v.Logger.Info(
+			"Response check matched.",
+			slog.String("package", logPackageName),
+			slog.String("name", finding.Name),
+			slog.String("severity", string(finding.Severity)),
+		)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+
+	return findings
+}
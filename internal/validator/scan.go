@@ -0,0 +1,963 @@
+package validator
+
+import (
+	"regexp/syntax"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// chunkSize is the size of the chunks the inputs are split in, to be scanned concurrently
+	// by the rules having no literal to look for.
+	chunkSize = 64 * 1024
+	// maxLiterals caps the number of literals a rule is prefiltered with.
+	maxLiterals = 64
+	// maxClassLiterals caps the size of the character classes whose characters are taken as literals.
+	maxClassLiterals = 8
+	// minLiteralLen is the length of the shortest literal a rule is prefiltered with. The shorter
+	// ones, such as '-' or '@', are in most lines of the inputs.
+	minLiteralLen = 2
+	// unbounded is the maximum length of the matches of a rule having no limit.
+	unbounded = -1
+)
+
+// scanner finds the matches of all the validation rules in an input. A single pass of an
+// automaton over the input finds where the literals all the matches of a rule contain are,
+// so that the rule only runs its regular expression around them, or not at all when there
+// are none. The rules without such literals only run where there are enough digits and
+// upper-case letters for a match, when they know how many, or else scan the whole input,
+// split in chunks scanned concurrently.
+type scanner struct {
+	// The size of the chunks the inputs are split in, chunkSize but in the tests.
+	chunkSize int
+	automaton *automaton
+	// The literals of the automaton, lower-cased, and the rules prefiltered by each of them.
+	literals     []string
+	literalRules [][]literalRule
+}
+
+// literal is a string all the matches of a rule contain.
+type literal struct {
+	text string
+	// Whether the case of the ASCII letters of the text does not matter.
+	fold bool
+	// The bytes that can follow the literal in the matches, nil when any can.
+	next *[256]bool
+}
+
+// literalRule is a rule prefiltered by a literal of the automaton, which ignores the case,
+// with the literal as the rule has it, checked again on each occurrence.
+type literalRule struct {
+	rule    int
+	literal literal
+}
+
+// region is the part of the input the matches of a rule are looked for in.
+type region struct {
+	start, end int
+}
+
+// scanTask is the scan of some regions of the input with a rule.
+type scanTask struct {
+	rule    int
+	regions []region
+}
+
+// newScanner builds the scanner of the compiled validation rules.
+func newScanner(validations []Validation) *scanner {
+	s := &scanner{chunkSize: chunkSize}
+
+	indexes := make(map[string]int)
+	for i, validation := range validations {
+		for _, l := range validation.literals {
+			text := lowerASCIIString(l.text)
+			index, ok := indexes[text]
+			if !ok {
+				index = len(s.literals)
+				indexes[text] = index
+				s.literals = append(s.literals, text)
+				s.literalRules = append(s.literalRules, nil)
+			}
+			s.literalRules[index] = append(s.literalRules[index], literalRule{rule: i, literal: l})
+		}
+	}
+	s.automaton = newAutomaton(s.literals)
+
+	return s
+}
+
+// scan returns the byte offsets of the matches of each rule in the input, in order.
+func (s *scanner) scan(validations []Validation, input string) [][][]int {
+	return s.scanWhere(validations, input, func(Validation) bool { return true })
+}
+
+// scanWhere returns the byte offsets of the matches in the input of each rule the filter
+// keeps, in order. The other rules have no matches.
+func (s *scanner) scanWhere(validations []Validation, input string, keep func(Validation) bool) [][][]int {
+	// The regions around the literals found, merged when they overlap.
+	regions := make([][]region, len(validations))
+	s.automaton.find(input, func(index int, end int) {
+		start := end - len(s.literals[index])
+		for _, lr := range s.literalRules[index] {
+			if !lr.literal.fold && input[start:end] != lr.literal.text ||
+				lr.literal.next != nil && (end == len(input) || !lr.literal.next[input[end]]) {
+				continue
+			}
+			if keep(validations[lr.rule]) {
+				regions[lr.rule] = addRegion(regions[lr.rule], validations[lr.rule].regionAround(input, start, end))
+			}
+		}
+	})
+
+	chunks := chunkBounds(input, s.chunkSize)
+	dense := newDensity(input)
+	var tasks []scanTask
+	for i, validation := range validations {
+		switch {
+		case !keep(validation):
+			continue
+		case validation.literals != nil:
+			if regions[i] != nil {
+				tasks = append(tasks, scanTask{rule: i, regions: regions[i]})
+			}
+		case validation.maxLen != unbounded && validation.counts != classCounts{}:
+			if r := dense.starts(validation); r != nil {
+				tasks = append(tasks, scanTask{rule: i, regions: r})
+			}
+		case validation.maxLen == unbounded && validation.crossesLines:
+			// The matches can span the whole input.
+			tasks = append(tasks, scanTask{rule: i, regions: []region{{0, len(input)}}})
+		default:
+			for _, chunk := range chunks {
+				tasks = append(tasks, scanTask{rule: i, regions: []region{chunk}})
+			}
+		}
+	}
+
+	results := make([][][]int, len(tasks))
+	runTasks(len(tasks), func(t int) {
+		task := tasks[t]
+		for _, r := range task.regions {
+			results[t] = append(results[t], validations[task.rule].findIn(input, r)...)
+		}
+	})
+
+	spans := make([][][]int, len(validations))
+	for t, task := range tasks {
+		spans[task.rule] = append(spans[task.rule], results[t]...)
+	}
+	for i := range spans {
+		spans[i] = dropOverlaps(spans[i])
+	}
+
+	return spans
+}
+
+// regionAround returns the region holding the matches of the rule that may contain
+// the literal found between the start and end offsets of the input.
+func (validation Validation) regionAround(input string, start int, end int) region {
+	if validation.maxLen != unbounded {
+		// The matches containing the literal start at most maxLen bytes before its end.
+		return region{max(0, end-validation.maxLen), start + 1}
+	}
+	if !validation.crossesLines {
+		// The matches containing the literal are in its line.
+		lineStart := strings.LastIndexByte(input[:start], '\n') + 1
+		lineEnd := len(input)
+		if newline := strings.IndexByte(input[end:], '\n'); newline >= 0 {
+			lineEnd = end + newline + 1
+		}
+		return region{lineStart, lineEnd}
+	}
+	return region{0, len(input)}
+}
+
+// addRegion adds the region to the regions of a rule, found in increasing order, merging it with the last one when they overlap.
+func addRegion(regions []region, r region) []region {
+	if n := len(regions); n > 0 && r.start <= regions[n-1].end {
+		regions[n-1].end = max(regions[n-1].end, r.end)
+		return regions
+	}
+	return append(regions, r)
+}
+
+// density finds the regions of an input holding enough digits and upper-case letters for
+// the matches of the rules having no literals. The offsets of the digits and upper-case
+// letters are only collected when a rule needs them, and the regions are shared by the
+// rules needing the same counts.
+type density struct {
+	input     string
+	collected bool
+	digits    []int32
+	upper     []int32
+	found     map[densityKey][]region
+}
+
+// densityKey identifies the regions holding the counts in maxLen bytes.
+type densityKey struct {
+	counts classCounts
+	maxLen int
+}
+
+// newDensity returns the density of the input.
+func newDensity(input string) *density {
+	return &density{input: input, found: make(map[densityKey][]region)}
+}
+
+// regions returns the regions holding the starts of the matches of at most maxLen bytes
+// holding the counts of digits and upper-case letters, nil when there are none.
+func (d *density) regions(counts classCounts, maxLen int) []region {
+	key := densityKey{counts: counts, maxLen: maxLen}
+	if regions, ok := d.found[key]; ok {
+		return regions
+	}
+
+	if !d.collected {
+		for i := 0; i < len(d.input); i++ {
+			if c := d.input[i]; c >= '0' && c <= '9' {
+				d.digits = append(d.digits, int32(i))
+			} else if c >= 'A' && c <= 'Z' {
+				d.upper = append(d.upper, int32(i))
+			}
+		}
+		d.collected = true
+	}
+
+	var regions []region
+	switch {
+	case counts.digits > 0 && counts.upper > 0:
+		// The upper-case letters are the fewer in most inputs.
+		if upper := windows(d.upper, counts.upper, maxLen); upper != nil {
+			regions = intersect(windows(d.digits, counts.digits, maxLen), upper)
+		}
+	case counts.digits > 0:
+		regions = windows(d.digits, counts.digits, maxLen)
+	default:
+		regions = windows(d.upper, counts.upper, maxLen)
+	}
+	d.found[key] = regions
+	return regions
+}
+
+// starts returns the offsets the matches of the rule can start at, as regions: those in the
+// regions of its counts, holding the bytes its matches start with, at a word boundary when they
+// start with one, and followed by enough digits and upper-case letters in maxLen bytes, or up to
+// the end of the line when the matches do not span lines.
+func (d *density) starts(validation Validation) []region {
+	var starts []region
+	for _, r := range d.regions(validation.counts, validation.maxLen) {
+		for i := r.start; i < r.end; i++ {
+			if validation.firstBytes != nil && !validation.firstBytes[d.input[i]] ||
+				validation.wordStart && i > 0 && isWordChar(d.input[i-1]) == isWordChar(d.input[i]) {
+				continue
+			}
+			end := min(len(d.input), i+validation.maxLen)
+			if newline := strings.IndexByte(d.input[i:end], '\n'); newline >= 0 && !validation.crossesLines {
+				end = i + newline
+			}
+			if count(d.digits, i, end) >= validation.counts.digits && count(d.upper, i, end) >= validation.counts.upper {
+				starts = addRegion(starts, region{i, i + 1})
+			}
+		}
+	}
+	return starts
+}
+
+// count returns the number of the sorted offsets between from and to.
+func count(offsets []int32, from int, to int) int {
+	end, _ := slices.BinarySearch(offsets, int32(to))
+	start, _ := slices.BinarySearch(offsets, int32(from))
+	return end - start
+}
+
+// windows returns the regions holding the starts of the matches of at most maxLen bytes
+// holding n of the characters found at the given offsets. The first n characters
+// of such a match follow each other in the offsets, and the match starts at most maxLen
+// bytes before the end of the last one.
+func windows(offsets []int32, n int, maxLen int) []region {
+	var regions []region
+	for i := n - 1; i < len(offsets); i++ {
+		first, last := int(offsets[i-n+1]), int(offsets[i])
+		if last-first < maxLen {
+			regions = addRegion(regions, region{max(0, last+1-maxLen), first + 1})
+		}
+	}
+	return regions
+}
+
+// intersect returns the parts of the input both sorted lists of regions cover.
+func intersect(a []region, b []region) []region {
+	var regions []region
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if start, end := max(a[i].start, b[j].start), min(a[i].end, b[j].end); start < end {
+			regions = append(regions, region{start, end})
+		}
+		if a[i].end < b[j].end {
+			i++
+		} else {
+			j++
+		}
+	}
+	return regions
+}
+
+// findIn returns the matches of the rule starting in the region of the input. The matches of
+// the rules with a maximum length are looked for a bit before and past the region, so that
+// those starting in it are found whole, with the assertions at their ends, such as word
+// boundaries, decided as in the whole input. The regions of the other rules are made of lines.
+func (validation Validation) findIn(input string, r region) [][]int {
+	from, limit := r.start, r.end
+	if validation.maxLen != unbounded {
+		from = max(0, r.start-validation.maxLen-utf8.UTFMax)
+		limit = min(len(input), r.end+validation.maxLen+utf8.UTFMax)
+	}
+
+	var matches [][]int
+	if validation.find != nil {
+		matches = validation.find(input[from:limit])
+	} else {
+		matches = validation.regex.FindAllStringIndex(input[from:limit], -1)
+	}
+
+	var spans [][]int
+	for _, span := range matches {
+		span[0] += from
+		span[1] += from
+		if span[0] < r.start {
+			continue
+		}
+		if span[0] >= r.end {
+			break
+		}
+		if validation.verify != nil && !validation.verify(input[span[0]:span[1]]) {
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// chunkBounds splits the input in chunks of about size bytes ending after a newline,
+// so that the matches not spanning lines are found whole in a chunk.
+func chunkBounds(input string, size int) []region {
+	var chunks []region
+	start := 0
+	for len(input)-start > size {
+		newline := strings.IndexByte(input[start+size:], '\n')
+		if newline < 0 {
+			break
+		}
+		end := start + size + newline + 1
+		chunks = append(chunks, region{start, end})
+		start = end
+	}
+	return append(chunks, region{start, len(input)})
+}
+
+// dropOverlaps sorts the matches of a rule and drops those overlapping a previous one,
+// found again by the scan of a neighbouring region.
+func dropOverlaps(spans [][]int) [][]int {
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+
+	kept := spans[:0]
+	for _, span := range spans {
+		if len(kept) > 0 && span[0] < kept[len(kept)-1][1] {
+			continue
+		}
+		kept = append(kept, span)
+	}
+	return kept
+}
+
+// runTasks runs the tasks on as many goroutines as there are processors.
+func runTasks(count int, task func(i int)) {
+	workers := min(count, runtime.GOMAXPROCS(0))
+	if workers <= 1 {
+		for i := 0; i < count; i++ {
+			task(i)
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				task(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// coverage tells how the literals of an expression relate to its matches.
+type coverage int
+
+const (
+	// contained literals are in every match.
+	contained coverage = iota
+	// prefixed literals start every match.
+	prefixed
+	// exact literals are the matches.
+	exact
+)
+
+// profile is what the scanner knows of the matches of a rule.
+type profile struct {
+	// The literals all the matches contain, nil when there are none, used to only run the rule
+	// around them, and to skip it on the inputs having none of them.
+	literals []literal
+	// The minimum and maximum lengths in bytes of the matches, maxLen being unbounded when there is no limit.
+	minLen int
+	maxLen int
+	// Whether the matches can span lines.
+	crossesLines bool
+	// The least numbers of digits and upper-case letters of the matches, used to only run the
+	// rules having no literals where there are enough of them.
+	counts classCounts
+	// The bytes the matches can start with, nil when they can start with any,
+	// and whether they start at a word boundary.
+	firstBytes *[256]bool
+	wordStart  bool
+}
+
+// analyze returns the profile of the matches of the pattern. The maximum length of the matches
+// is unbounded when there is no limit or the pattern is anchored to the start or end of the text.
+func analyze(pattern string) profile {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return profile{maxLen: unbounded, crossesLines: true}
+	}
+	re = re.Simplify()
+
+	literals, _ := requiredLiterals(re)
+	for _, l := range literals {
+		if len(l.text) < minLiteralLen {
+			literals = nil
+			break
+		}
+	}
+
+	first, empty := firstBytes(re)
+	if empty {
+		first = nil
+	}
+
+	return profile{
+		literals:     literals,
+		minLen:       minLength(re),
+		maxLen:       maxLength(re),
+		crossesLines: crossesLines(re),
+		counts:       countClasses(re),
+		firstBytes:   first,
+		wordStart:    startsAtWordBoundary(re),
+	}
+}
+
+// requiredLiterals returns a set of literals one of which is in every match of the expression,
+// nil when there is none, and how they relate to the matches. The empty-width assertions give
+// the empty literal.
+func requiredLiterals(re *syntax.Regexp) ([]literal, coverage) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		text := string(re.Rune)
+		if re.Flags&syntax.FoldCase == 0 {
+			return []literal{{text: text}}, exact
+		}
+		// The automaton only folds ASCII letters.
+		if !isASCII(text) {
+			return nil, contained
+		}
+		literals := foldedLiterals(text)
+		if literals == nil {
+			return nil, contained
+		}
+		return literals, exact
+	case syntax.OpCharClass:
+		var literals []literal
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(literals) == maxClassLiterals {
+					return nil, contained
+				}
+				literals = appendUnique(literals, literal{text: string(r)})
+			}
+		}
+		if literals == nil {
+			return nil, contained
+		}
+		return literals, exact
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []literal{{}}, exact
+	case syntax.OpCapture:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpPlus:
+		literals, cov := requiredLiterals(re.Sub[0])
+		return literals, min(cov, prefixed)
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil, contained
+		}
+		literals, cov := requiredLiterals(re.Sub[0])
+		if re.Min == 1 && re.Max == 1 {
+			return literals, cov
+		}
+		return literals, min(cov, prefixed)
+	case syntax.OpAlternate:
+		var literals []literal
+		cov := exact
+		for _, sub := range re.Sub {
+			subLiterals, subCov := requiredLiterals(sub)
+			if subLiterals == nil {
+				return nil, contained
+			}
+			for _, l := range subLiterals {
+				literals = appendUnique(literals, l)
+			}
+			cov = min(cov, subCov)
+		}
+		if len(literals) > maxLiterals {
+			return nil, contained
+		}
+		return literals, cov
+	case syntax.OpConcat:
+		return concatLiterals(re.Sub)
+	default:
+		return nil, contained
+	}
+}
+
+// concatLiterals returns the most selective set of literals of the concatenated expressions.
+// The literals of consecutive exact expressions are joined with those of the expression
+// following them when they start its matches, e.g. 'gh', a class of letters and '_'.
+func concatLiterals(subs []*syntax.Regexp) ([]literal, coverage) {
+	var best []literal
+	var prefix []literal
+	run := []literal{{}}
+	// Whether the run joins the literals of all the expressions so far.
+	fromStart := true
+	for _, sub := range subs {
+		literals, cov := requiredLiterals(sub)
+		if literals == nil {
+			run = followedBy(run, sub)
+			if fromStart {
+				prefix = run
+			}
+			best = mostSelective(best, run)
+			run, fromStart = []literal{{}}, false
+			continue
+		}
+
+		if len(run)*len(literals) > maxLiterals {
+			run = followedBy(run, sub)
+			if fromStart {
+				prefix = run
+			}
+			best = mostSelective(best, run)
+			run, fromStart = nil, false
+		}
+
+		best = mostSelective(best, literals)
+		if cov == contained {
+			// The literals are somewhere in the matches, not next to the run.
+			run = followedBy(run, sub)
+			if fromStart {
+				prefix = run
+			}
+			best = mostSelective(best, run)
+			run, fromStart = []literal{{}}, false
+			continue
+		}
+
+		if run == nil {
+			run = literals
+		} else {
+			run = crossJoin(run, literals)
+		}
+		if cov == prefixed {
+			if fromStart {
+				prefix = run
+			}
+			best = mostSelective(best, run)
+			run, fromStart = []literal{{}}, false
+		}
+	}
+
+	if fromStart {
+		return run, exact
+	}
+	best = mostSelective(best, run)
+	if prefix != nil && minLen(prefix) >= minLen(best) {
+		return prefix, prefixed
+	}
+	return best, contained
+}
+
+// followedBy returns the literals, with the bytes the matches of the expression following
+// them start with.
+func followedBy(literals []literal, re *syntax.Regexp) []literal {
+	next, empty := firstBytes(re)
+	if next == nil || empty {
+		return literals
+	}
+	followed := make([]literal, len(literals))
+	for i, l := range literals {
+		l.next = next
+		followed[i] = l
+	}
+	return followed
+}
+
+// mostSelective returns the set of literals whose shortest literal is the longest.
+func mostSelective(best []literal, candidate []literal) []literal {
+	if candidate == nil || (best != nil && minLen(candidate) <= minLen(best)) {
+		return best
+	}
+	return candidate
+}
+
+// minLength returns the minimum length in bytes of the matches of the expression.
+func minLength(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return len(re.Rune)
+		}
+		return len(string(re.Rune))
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1
+	case syntax.OpCapture, syntax.OpPlus:
+		return minLength(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min * minLength(re.Sub[0])
+	case syntax.OpConcat:
+		total := 0
+		for _, sub := range re.Sub {
+			total += minLength(sub)
+		}
+		return total
+	case syntax.OpAlternate:
+		least := minLength(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			least = min(least, minLength(sub))
+		}
+		return least
+	default:
+		// The empty-width assertions, such as word boundaries, the empty matches, and the
+		// expressions repeated from zero times.
+		return 0
+	}
+}
+
+// maxLength returns the maximum length in bytes of the matches of the expression, or unbounded.
+func maxLength(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return len(re.Rune) * utf8.UTFMax
+		}
+		return len(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0
+		}
+		return utf8.RuneLen(min(re.Rune[len(re.Rune)-1], utf8.MaxRune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return utf8.UTFMax
+	case syntax.OpBeginText, syntax.OpEndText:
+		return unbounded
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxLength(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		return unbounded
+	case syntax.OpRepeat:
+		sub := maxLength(re.Sub[0])
+		if re.Max == -1 || sub == unbounded {
+			return unbounded
+		}
+		return re.Max * sub
+	case syntax.OpConcat, syntax.OpAlternate:
+		total := 0
+		for _, sub := range re.Sub {
+			length := maxLength(sub)
+			if length == unbounded {
+				return unbounded
+			}
+			if re.Op == syntax.OpConcat {
+				total += length
+			} else {
+				total = max(total, length)
+			}
+		}
+		return total
+	default:
+		// The empty-width assertions, such as word boundaries, and the empty matches.
+		return 0
+	}
+}
+
+// crossesLines reports whether the matches of the expression can hold a newline,
+// or depend on the start or end of the text.
+func crossesLines(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return strings.ContainsRune(string(re.Rune), '\n')
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	case syntax.OpAnyChar, syntax.OpBeginText, syntax.OpEndText:
+		return true
+	default:
+		for _, sub := range re.Sub {
+			if crossesLines(sub) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// firstBytes returns the bytes the matches of the expression can start with, nil when they
+// can start with any, and whether the expression matches the empty string.
+func firstBytes(re *syntax.Regexp) (*[256]bool, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		first := &[256]bool{}
+		if len(re.Rune) == 0 {
+			return first, true
+		}
+		addRune(first, re.Rune[0], re.Flags&syntax.FoldCase != 0)
+		return first, false
+	case syntax.OpCharClass:
+		first := &[256]bool{}
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= min(re.Rune[i+1], utf8.RuneSelf-1); r++ {
+				first[r] = true
+			}
+			if re.Rune[i+1] >= utf8.RuneSelf {
+				addRune(first, re.Rune[i+1], false)
+			}
+		}
+		return first, false
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return &[256]bool{}, true
+	case syntax.OpCapture, syntax.OpPlus:
+		return firstBytes(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		first, _ := firstBytes(re.Sub[0])
+		return first, true
+	case syntax.OpRepeat:
+		first, empty := firstBytes(re.Sub[0])
+		return first, empty || re.Min == 0
+	case syntax.OpConcat, syntax.OpAlternate:
+		first := &[256]bool{}
+		empty := re.Op == syntax.OpConcat
+		for _, sub := range re.Sub {
+			subFirst, subEmpty := firstBytes(sub)
+			if subFirst == nil {
+				return nil, false
+			}
+			for c, ok := range subFirst {
+				first[c] = first[c] || ok
+			}
+			if re.Op == syntax.OpAlternate {
+				empty = empty || subEmpty
+			} else if !subEmpty {
+				return first, false
+			}
+		}
+		return first, empty
+	default:
+		return nil, false
+	}
+}
+
+// addRune adds the first byte of the rune to the bytes, those of its ASCII case counterpart
+// when fold is set, and all the non-ASCII bytes for the non-ASCII runes and the letters
+// Unicode case folding maps to them.
+func addRune(first *[256]bool, r rune, fold bool) {
+	if r >= utf8.RuneSelf || fold && strings.ContainsRune("kKsS", r) {
+		for c := utf8.RuneSelf; c < len(first); c++ {
+			first[c] = true
+		}
+	}
+	if r >= utf8.RuneSelf {
+		return
+	}
+	first[r] = true
+	if fold {
+		first[lowerASCII(byte(r))] = true
+		first[unicode.ToUpper(r)] = true
+	}
+}
+
+// startsAtWordBoundary reports whether the matches of the expression start at a word boundary.
+func startsAtWordBoundary(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpWordBoundary:
+		return true
+	case syntax.OpCapture, syntax.OpConcat:
+		return startsAtWordBoundary(re.Sub[0])
+	default:
+		return false
+	}
+}
+
+// isWordChar reports whether c is an ASCII word character, as with \b.
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// classCounts are the least numbers of ASCII digits and upper-case letters in the matches of an expression.
+type classCounts struct {
+	digits int
+	upper  int
+}
+
+// countClasses returns the least numbers of digits and upper-case letters in the matches of the expression.
+func countClasses(re *syntax.Regexp) classCounts {
+	switch re.Op {
+	case syntax.OpLiteral:
+		var counts classCounts
+		for _, r := range re.Rune {
+			switch {
+			case r >= '0' && r <= '9':
+				counts.digits++
+			case r >= 'A' && r <= 'Z' && re.Flags&syntax.FoldCase == 0:
+				counts.upper++
+			}
+		}
+		return counts
+	case syntax.OpCharClass:
+		switch {
+		case inRange(re.Rune, '0', '9'):
+			return classCounts{digits: 1}
+		case inRange(re.Rune, 'A', 'Z'):
+			return classCounts{upper: 1}
+		}
+		return classCounts{}
+	case syntax.OpCapture, syntax.OpPlus:
+		return countClasses(re.Sub[0])
+	case syntax.OpRepeat:
+		counts := countClasses(re.Sub[0])
+		return classCounts{digits: counts.digits * re.Min, upper: counts.upper * re.Min}
+	case syntax.OpConcat:
+		var counts classCounts
+		for _, sub := range re.Sub {
+			subCounts := countClasses(sub)
+			counts.digits += subCounts.digits
+			counts.upper += subCounts.upper
+		}
+		return counts
+	case syntax.OpAlternate:
+		counts := countClasses(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			subCounts := countClasses(sub)
+			counts.digits = min(counts.digits, subCounts.digits)
+			counts.upper = min(counts.upper, subCounts.upper)
+		}
+		return counts
+	default:
+		return classCounts{}
+	}
+}
+
+// inRange reports whether the ranges of a character class are all between lo and hi.
+func inRange(ranges []rune, lo rune, hi rune) bool {
+	if len(ranges) == 0 {
+		return false
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if ranges[i] < lo || ranges[i+1] > hi {
+			return false
+		}
+	}
+	return true
+}
+
+// crossJoin returns every prefix followed by every suffix, ignoring the case when either does.
+func crossJoin(prefixes []literal, suffixes []literal) []literal {
+	joined := make([]literal, 0, len(prefixes)*len(suffixes))
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			joined = append(joined, literal{text: prefix.text + suffix.text, fold: prefix.fold || suffix.fold, next: suffix.next})
+		}
+	}
+	return joined
+}
+
+// foldedLiterals returns the literals matching the text ignoring its case, nil when there are
+// more than maxLiterals. Unicode case folding maps k and s to the Kelvin and long s signs as
+// well, which the automaton does not fold, so they are literals of their own.
+func foldedLiterals(text string) []literal {
+	literals := []literal{{fold: true}}
+	for _, r := range lowerASCIIString(text) {
+		variants := []literal{{text: string(r), fold: true}}
+		switch r {
+		case 'k':
+			variants = append(variants, literal{text: "\u212a", fold: true})
+		case 's':
+			variants = append(variants, literal{text: "\u017f", fold: true})
+		}
+		if literals = crossJoin(literals, variants); len(literals) > maxLiterals {
+			return nil
+		}
+	}
+	return literals
+}
+
+// minLen returns the length of the shortest literal.
+func minLen(literals []literal) int {
+	shortest := len(literals[0].text)
+	for _, l := range literals[1:] {
+		shortest = min(shortest, len(l.text))
+	}
+	return shortest
+}
+
+// isASCII reports whether s only holds ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerASCIIString lower-cases the ASCII letters of s, leaving the other characters as they are.
+func lowerASCIIString(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = lowerASCII(c)
+	}
+	return string(b)
+}
+
+// appendUnique appends the literal unless it is already there.
+func appendUnique(literals []literal, l literal) []literal {
+	if slices.Contains(literals, l) {
+		return literals
+	}
+	return append(literals, l)
+}
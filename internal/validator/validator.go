@@ -1,59 +1,192 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 
 	"log/slog"
 
-	"github.com/spf13/viper"
+	"github.com/nycruz/gail/internal/models"
 )
 
+// Action sets what happens to a prompt matching a validation rule.
+type Action string
+
+const (
+	// ActionBlock refuses to send the prompt.
+	ActionBlock Action = "block"
+	// ActionWarn asks the user to confirm before sending the prompt.
+	ActionWarn Action = "warn"
+	// ActionRedact replaces the matches with placeholders before sending the prompt,
+	// and restores the original values in the answer.
+	ActionRedact Action = "redact"
+)
+
+// Severity ranks how harmful leaking a match of a validation rule would be.
+type Severity string
+
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// Finding is a match of a validation rule in the user input.
+type Finding struct {
+	// The name of the rule that matched.
+	Name     string
+	Action   Action
+	Severity Severity
+	// The byte offsets of the match in the user input.
+	Start int
+	End   int
+	// The matched text.
+	Value string
+	// Whether the rule is locked, so that its matches cannot be allowed.
+	Locked bool
+}
+
 type Validator struct {
-	Logger      *slog.Logger
+	Logger *slog.Logger
+	// The action of the rules that do not set one.
+	Mode        Action
 	Validations []Validation
+	// The external commands validating the prompt along with the rules.
+	Hooks []Hook
+	// The rules flagging the dangerous commands and the secrets in the answers of the models.
+	ResponseValidations []Validation
+	// The moderation check of the prompts, nil when there is none or it is skipped.
+	Moderation *Moderation
+
+	// The placeholders given to the redacted values during the session, and the other way around.
+	// They are shared by all the models, so that a value keeps its placeholder across a conversation.
+	mu           sync.Mutex
+	placeholders map[string]models.Substitution
+	values       map[string]models.Substitution
+	// The number of placeholders given per label.
+	counters map[string]int
+	// The values no rule reports, read from the global allowlist file.
+	allowlist allowlist
+	// The values the user allowed for the session.
+	sessionAllowed map[string]bool
+	// Held while the hooks run, so that the models validating the same prompt at once, as in
+	// compare mode, wait for the findings of the first one instead of running the hooks again.
+	hookMu sync.Mutex
+	// The last input the hooks ran on and their findings, nil when they have not run yet. Guarded by hookMu.
+	lastHookInput    *string
+	lastHookFindings []Finding
+	// Held while the moderation check runs, for the same reason.
+	moderationMu sync.Mutex
+	// The last input the moderation check ran on and its findings, nil when it has not run yet. Guarded by moderationMu.
+	lastModeratedInput     *string
+	lastModerationFindings []Finding
+	// The values the hooks asked to redact, with the name of their finding.
+	hookRedactions map[string]string
+	// The scanners finding the matches of all the rules, and of all the response rules, at once.
+	scanner         *scanner
+	responseScanner *scanner
 }
 
 type ValidateConfig struct {
+	Mode Action `mapstructure:"mode"`
+	// The names of the built-in detectors to enable, or "all".
+	Detectors []string `mapstructure:"detectors"`
+	// The names of the built-in regional rule packs to enable (e.g. eu, uk).
+	Packs       []string     `mapstructure:"packs"`
 	Validations []Validation `mapstructure:"validation"`
+	Hooks       []Hook       `mapstructure:"hook"`
+	// The checks of the answers of the models.
+	Response ResponseConfig `mapstructure:"response"`
+	// The moderation check of the prompts, nil when there is none.
+	Moderation *Moderation `mapstructure:"moderation"`
 }
 
 type Validation struct {
-	Name    string `mapstructure:"name"`
+	Name string `mapstructure:"name"`
+	// The regular expression finding the candidates, which must match at least one character.
+	// Optional for the kinds having a default one.
 	Pattern string `mapstructure:"pattern"`
+	// The check the candidates must pass to be reported (luhn, iban or ssn). Every candidate is reported when empty.
+	Kind Kind `mapstructure:"kind"`
+	// What happens to a prompt matching the rule: block, warn or redact. Defaults to the file's mode.
+	Action Action `mapstructure:"action"`
+	// How harmful leaking a match would be: low, medium or high. Defaults to high.
+	Severity Severity `mapstructure:"severity"`
+	// The label of the placeholders replacing the matches with the redact action (e.g. EMAIL gives <EMAIL_1>).
+	// Defaults to the upper-cased name.
+	Placeholder string `mapstructure:"placeholder"`
+	// The matches not to report: networks in CIDR notation, or regular expressions matching the whole value.
+	Allow []string `mapstructure:"allow"`
+	// Whether the rule is mandatory: the later layers cannot replace it, and its matches
+	// are neither allowed by the global allowlist nor for the session.
+	Locked bool `mapstructure:"locked"`
+	// The layer and file the rule comes from.
+	Source string `mapstructure:"-"`
+	// Whether the rule is a built-in detector.
+	Builtin bool `mapstructure:"-"`
+	// The name of the built-in pack the rule comes from, empty for the rules of the file.
+	Pack string `mapstructure:"-"`
+
+	// Whether a match of the pattern is a real finding, nil when they all are. Set by the built-in detectors.
+	verify func(match string) bool
+	// The compiled pattern.
+	regex *regexp.Regexp
+	// The compiled Allow entries.
+	allowlist allowlist
+	// What the scanner knows of the matches of the pattern.
+	profile
+	// A faster equivalent of the pattern's FindAllStringIndex, nil when there is none. Set by the built-in detectors.
+	find func(input string) [][]int
 }
 
 const (
 	logPackageName = "validator"
 )
 
-// New creates a new Validator struct with the given validator path.
+// placeholderRegex matches the placeholders given to redacted values (e.g. <EMAIL_1>).
+var placeholderRegex = regexp.MustCompile(`<[A-Z0-9_]+_\d+>`)
+
+// New creates a new Validator struct with the rules of the system, user and project validation files.
 func New(logger *slog.Logger, validationsFilename string, configDirPath string) (*Validator, error) {
-	fileExt := "toml"
-	viper.SetConfigName(validationsFilename)
-	viper.SetConfigType(fileExt)
-	viper.AddConfigPath(configDirPath)
-	err := viper.ReadInConfig()
+	p, err := readLayers(logger, validationsFilename, configDirPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read the '%s.%s' config file: %w", validationsFilename, fileExt, err)
+		return nil, err
 	}
 
-	var vc *ValidateConfig
-	err = viper.Unmarshal(&vc)
+	globalAllowlist, err := readAllowlist(configDirPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal the '%s.%s' config file: %w", validationsFilename, fileExt, err)
+		return nil, err
 	}
 
 	v := &Validator{
-		Logger:      logger,
-		Validations: vc.Validations,
+		Logger:              logger,
+		Mode:                p.mode,
+		Validations:         p.validations,
+		Hooks:               p.hooks,
+		ResponseValidations: p.responseValidations,
+		Moderation:          p.moderation,
+		placeholders:        make(map[string]models.Substitution),
+		values:              make(map[string]models.Substitution),
+		counters:            make(map[string]int),
+		allowlist:           globalAllowlist,
+
+		sessionAllowed:  make(map[string]bool),
+		hookRedactions:  make(map[string]string),
+		scanner:         newScanner(p.validations),
+		responseScanner: newScanner(p.responseValidations),
 	}
 
 	return v, nil
 }
 
-// Validate checks if the given user input matches any of the validation rules.
-func (v *Validator) Validate(userInput string) (string, bool) {
+// Validate returns the matches of the validation rules and the findings of the hooks and
+// of the moderation check in the given user input, in order of appearance.
+func (v *Validator) Validate(userInput string) []Finding {
 	numValidations := len(v.Validations)
 	v.Logger.Info(
 		"Found validation rules.",
@@ -61,19 +194,428 @@ func (v *Validator) Validate(userInput string) (string, bool) {
 		slog.Int("rules_count", numValidations),
 	)
 
-	for _, validation := range v.Validations {
-		regex := regexp.MustCompile(validation.Pattern)
-		if regex.MatchString(userInput) {
-			v.Logger.Info(
-				"Validation matched.",
+	spans := v.scanner.scan(v.Validations, userInput)
+
+	v.mu.Lock()
+	var findings []Finding
+	for i, validation := range v.Validations {
+		for _, span := range spans[i] {
+			if v.isAllowed(validation, userInput[span[0]:span[1]]) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Name:     validation.Name,
+				Action:   validation.Action,
+				Severity: validation.Severity,
+				Start:    span[0],
+				End:      span[1],
+				Value:    userInput[span[0]:span[1]],
+				Locked:   validation.Locked,
+			})
+		}
+	}
+	v.mu.Unlock()
+
+	for _, finding := range v.runHooks(userInput) {
+		v.mu.Lock()
+		isAllowed := finding.Value != "" && v.isAllowed(Validation{Locked: finding.Locked}, finding.Value)
+		v.mu.Unlock()
+		if isAllowed {
+			continue
+		}
+		findings = append(findings, finding)
+	}
+
+	// The moderation check sends the prompt out, so it is skipped when the prompt is refused anyway.
+	if _, isBlocked := BlockingMessage(findings); !isBlocked {
+		findings = append(findings, v.runModeration(userInput)...)
+	}
+
+	// The inputs can hold thousands of matches, so each rule is logged once, with its number of matches.
+	matches := make(map[string]int)
+	var firsts []Finding
+	for _, finding := range findings {
+		if matches[finding.Name] == 0 {
+			firsts = append(firsts, finding)
+		}
+		matches[finding.Name]++
+	}
+	for _, finding := range firsts {
+		v.Logger.Info(
+			"Validation matched.",
+			slog.String("package", logPackageName),
+			slog.String("name", finding.Name),
+			slog.String("action", string(finding.Action)),
+			slog.String("severity", string(finding.Severity)),
+			slog.Int("matches", matches[finding.Name]),
+		)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+
+	return findings
+}
+
+// BlockingMessage returns the message explaining why the input is refused,
+// and whether it is, when some of the findings have the block action.
+func BlockingMessage(findings []Finding) (string, bool) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, finding := range findings {
+		if finding.Action != ActionBlock || seen[finding.Name] {
+			continue
+		}
+		seen[finding.Name] = true
+		names = append(names, finding.Name)
+	}
+
+	if len(names) == 0 {
+		return "", false
+	}
+
+	return fmt.Sprintf("Your input contains sensitive information: %s.\n Please try again!", strings.Join(names, ", ")), true
+}
+
+// Filter returns the findings having the given action.
+func Filter(findings []Finding, action Action) []Finding {
+	var filtered []Finding
+	for _, finding := range findings {
+		if finding.Action == action {
+			filtered = append(filtered, finding)
+		}
+	}
+	return filtered
+}
+
+// Redact replaces the matches of the validation rules having the redact action, and the values
+// the hooks asked to redact, with placeholders.
+// The matches are all found in the original input, and a match overlapping the placeholders
+// already in it, or a match of a previous rule, is left alone.
+// A value keeps the same placeholder for the whole session. The substitutions made are
+// returned in order of appearance.
+func (v *Validator) Redact(userInput string) (string, []models.Substitution) {
+	spans := v.scanner.scanWhere(v.Validations, userInput, func(validation Validation) bool {
+		return validation.Action == ActionRedact
+	})
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var redactions []redaction
+	taken := placeholderRegex.FindAllStringIndex(userInput, -1)
+	for i, validation := range v.Validations {
+		for _, span := range spans[i] {
+			if v.isAllowed(validation, userInput[span[0]:span[1]]) {
+				continue
+			}
+			var isFree bool
+			if taken, isFree = take(taken, span); !isFree {
+				continue
+			}
+			redactions = append(redactions, redaction{start: span[0], end: span[1], validation: validation})
+		}
+	}
+
+	// The values the hooks asked to redact are looked for longest first, so that a value
+	// containing another one keeps its own placeholder.
+	values := make([]string, 0, len(v.hookRedactions))
+	for value := range v.hookRedactions {
+		if strings.Contains(userInput, value) && !v.isAllowed(Validation{}, value) {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, value := range values {
+		validation := Validation{Name: v.hookRedactions[value]}
+		for offset := 0; ; {
+			index := strings.Index(userInput[offset:], value)
+			if index < 0 {
+				break
+			}
+			span := []int{offset + index, offset + index + len(value)}
+			offset = span[1]
+			var isFree bool
+			if taken, isFree = take(taken, span); !isFree {
+				continue
+			}
+			redactions = append(redactions, redaction{start: span[0], end: span[1], validation: validation})
+		}
+	}
+
+	sort.Slice(redactions, func(i, j int) bool {
+		return redactions[i].start < redactions[j].start
+	})
+
+	var b strings.Builder
+	var substitutions []models.Substitution
+	seen := make(map[string]bool)
+	last := 0
+	for _, r := range redactions {
+		substitution := v.placeholderFor(r.validation, userInput[r.start:r.end])
+		if !seen[substitution.Placeholder] {
+			seen[substitution.Placeholder] = true
+			substitutions = append(substitutions, substitution)
+		}
+		b.WriteString(userInput[last:r.start])
+		b.WriteString(substitution.Placeholder)
+		last = r.end
+	}
+	b.WriteString(userInput[last:])
+
+	if len(substitutions) > 0 {
+		v.Logger.Info(
+			"Redacted the input.",
+			slog.String("package", logPackageName),
+			slog.Int("substitutions_count", len(substitutions)),
+		)
+	}
+
+	return b.String(), substitutions
+}
+
+// redaction is a part of the input to replace with a placeholder.
+type redaction struct {
+	start, end int
+	// The rule that matched the part, named after the finding for the values of the hooks.
+	validation Validation
+}
+
+// take adds the span to the sorted spans not overlapping each other, unless it overlaps one of
+// them, and reports whether it was added.
+func take(spans [][]int, span []int) ([][]int, bool) {
+	// The first span ending past the start of the span is the only one it may overlap.
+	i := sort.Search(len(spans), func(i int) bool { return spans[i][1] > span[0] })
+	if i < len(spans) && spans[i][0] < span[1] {
+		return spans, false
+	}
+	return slices.Insert(spans, i, span), true
+}
+
+// Restore replaces the placeholders given during the session with their original values.
+func (v *Validator) Restore(text string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return placeholderRegex.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if substitution, ok := v.values[placeholder]; ok {
+			return substitution.Value
+		}
+		return placeholder
+	})
+}
+
+// AllowForSession stops reporting the values of the findings until Gail exits.
+// Each override is logged, with the value masked.
+func (v *Validator) AllowForSession(findings []Finding) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, finding := range findings {
+		// The findings of a hook on the whole prompt have no value to allow.
+		if finding.Value == "" {
+			continue
+		}
+		if finding.Locked {
+			v.Logger.Warn(
+				"Validation not overridden: the rule is locked.",
 				slog.String("package", logPackageName),
-				slog.String("name", validation.Name),
+				slog.String("name", finding.Name),
 			)
+			continue
+		}
+		v.sessionAllowed[finding.Value] = true
+		v.Logger.Warn(
+			"Validation overridden: value allowed for the session.",
+			slog.String("package", logPackageName),
+			slog.String("name", finding.Name),
+			slog.String("severity", string(finding.Severity)),
+			slog.String("value", mask(finding.Value)),
+		)
+	}
+}
+
+// isAllowed reports whether the value matched by the validation rule is allowed by the
+// rule's allowlist, the global allowlist or the user for the session. The matches of a locked
+// rule are only allowed by its own allowlist. The caller must hold the lock.
+func (v *Validator) isAllowed(validation Validation, value string) bool {
+	if validation.allowlist.allows(value) {
+		return true
+	}
+	if validation.Locked {
+		return false
+	}
+	return v.sessionAllowed[value] || v.allowlist.allows(value)
+}
 
-			displayErrorMessage := fmt.Sprintf("Your input contains Personal Identifiable Information: %s.\n Please try again!", validation.Name)
-			return displayErrorMessage, false
+// mask hides all but the first characters of a sensitive value, to log it.
+func mask(value string) string {
+	const visible = 2
+	runes := []rune(value)
+	if len(runes) <= visible*2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:visible]) + strings.Repeat("*", len(runes)-visible)
+}
+
+// applyKinds sets the check of the validation rules having a kind, and their default pattern when they have none.
+func applyKinds(validations []Validation) error {
+	for i, validation := range validations {
+		if validation.Kind == KindRegex {
+			if validation.Pattern == "" {
+				return fmt.Errorf("validation '%s' has no pattern", validation.Name)
+			}
+			continue
+		}
+
+		check, ok := kindChecks[validation.Kind]
+		if !ok {
+			return fmt.Errorf("validation '%s' has the unknown kind '%s', expected one of: %s", validation.Name, validation.Kind, kindNames())
 		}
+		if validation.Pattern == "" {
+			validations[i].Pattern = check.pattern
+		}
+		validations[i].verify = check.verify
 	}
+	return nil
+}
+
+// compile compiles the patterns and allowlists of the validation rules, reporting all the invalid ones,
+// and the patterns that can match the empty string.
+func compile(validations []Validation) error {
+	var errs []error
+	for i, validation := range validations {
+		regex, err := regexp.Compile(validation.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("validation '%s': %w", validation.Name, err))
+			continue
+		}
+		validations[i].regex = regex
+		validations[i].profile = analyze(validation.Pattern)
+		if validations[i].minLen == 0 {
+			// Such as '\b' or 'x*' alone: the empty matches have no value to report.
+			errs = append(errs, fmt.Errorf("validation '%s': the pattern can match the empty string, it must match at least one character", validation.Name))
+			continue
+		}
+
+		a, err := newAllowlist(validation.Allow)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("validation '%s': %w", validation.Name, err))
+			continue
+		}
+		validations[i].allowlist = a
+	}
+	return errors.Join(errs...)
+}
+
+// applyDefaults sets the action and severity of the validation rules that do not set them, and checks the others.
+func applyDefaults(validations []Validation, mode Action) error {
+	for i, validation := range validations {
+		if validation.Action == "" {
+			validations[i].Action = mode
+		} else if !isAction(validation.Action) {
+			return fmt.Errorf("validation '%s' has the unknown action '%s', expected one of: %s, %s, %s", validation.Name, validation.Action, ActionBlock, ActionWarn, ActionRedact)
+		}
 
-	return "", true
+		if validation.Severity == "" {
+			validations[i].Severity = SeverityHigh
+		} else if !isSeverity(validation.Severity) {
+			return fmt.Errorf("validation '%s' has the unknown severity '%s', expected one of: %s, %s, %s", validation.Name, validation.Severity, SeverityLow, SeverityMedium, SeverityHigh)
+		}
+	}
+	return nil
+}
+
+// isAction reports whether the action is one of the known ones.
+func isAction(action Action) bool {
+	switch action {
+	case ActionBlock, ActionWarn, ActionRedact:
+		return true
+	}
+	return false
+}
+
+// isSeverity reports whether the severity is one of the known ones.
+func isSeverity(severity Severity) bool {
+	switch severity {
+	case SeverityLow, SeverityMedium, SeverityHigh:
+		return true
+	}
+	return false
+}
+
+// withDetectors appends the built-in detectors enabled by name to the validation rules.
+func withDetectors(validations []Validation, names []string) ([]Validation, error) {
+	for _, name := range names {
+		if name == DetectorAll {
+			names = DetectorNames()
+			break
+		}
+	}
+
+	for _, name := range names {
+		d, ok := findDetector(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown detector '%s', expected one of: %s", name, strings.Join(DetectorNames(), ", "))
+		}
+		validations = append(validations, Validation{
+			Name:    d.name,
+			Pattern: d.pattern,
+			Builtin: true,
+			verify:  d.verify,
+			find:    d.find,
+		})
+	}
+
+	return validations, nil
+}
+
+// placeholderFor returns the substitution of the value, giving it a new placeholder
+// the first time it is seen. The caller must hold the lock.
+func (v *Validator) placeholderFor(validation Validation, value string) models.Substitution {
+	if substitution, ok := v.placeholders[value]; ok {
+		return substitution
+	}
+
+	label := placeholderLabel(validation)
+	v.counters[label]++
+
+	substitution := models.Substitution{
+		Placeholder: fmt.Sprintf("<%s_%d>", label, v.counters[label]),
+		Name:        validation.Name,
+		Value:       value,
+	}
+	v.placeholders[value] = substitution
+	v.values[substitution.Placeholder] = substitution
+
+	return substitution
+}
+
+// placeholderLabel returns the label of the placeholders of the validation rule,
+// its name upper-cased with anything but letters and digits turned into underscores.
+func placeholderLabel(validation Validation) string {
+	label := validation.Placeholder
+	if label == "" {
+		label = validation.Name
+	}
+
+	label = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, label)
+	label = strings.Trim(label, "_")
+
+	if label == "" {
+		return "REDACTED"
+	}
+	return label
 }
@@ -2,18 +2,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"log/slog"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/nycruz/gail/internal/assistant"
+	"github.com/nycruz/gail/internal/audit"
+	"github.com/nycruz/gail/internal/cassette"
 	"github.com/nycruz/gail/internal/config"
 	"github.com/nycruz/gail/internal/logger"
 	"github.com/nycruz/gail/internal/models"
 	"github.com/nycruz/gail/internal/models/claude"
+	"github.com/nycruz/gail/internal/models/fallback"
 	"github.com/nycruz/gail/internal/models/gpt"
 	"github.com/nycruz/gail/internal/models/gpto"
+	"github.com/nycruz/gail/internal/models/mock"
+	"github.com/nycruz/gail/internal/pipeline"
 	"github.com/nycruz/gail/internal/tui"
 	"github.com/nycruz/gail/internal/validator"
 )
@@ -22,10 +32,22 @@ const (
 	AppName             = "Gail"
 	ValidationsFileName = "validations"
 	AssistantsFileName  = "assistants"
+	MockScriptFileName  = "mock.toml"
 )
 
 func main() {
-	modelFlag := flag.String("model", "gpt", "The model to use for the chat completion (e.g. gpt, gpt-o, claude)")
+	if ok, err := runCommand(os.Args[1:]); ok {
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		return
+	}
+
+	modelFlag := flag.String("model", "gpt", "The model to use for the chat completion (e.g. gpt, gpt-o, claude, mock)")
+	compareFlag := flag.String("compare", "", "A comma separated list of models to send each prompt to side by side (e.g. gpt,gpt-o,claude)")
+	mockScriptFlag := flag.String("mock-script", "", "The script file the mock model answers from (default: mock.toml in the config directory)")
+	recordFlag := flag.String("record", "", "A directory to record the requests sent to the models and their responses to")
+	replayFlag := flag.String("replay", "", "A directory to replay recorded responses from, instead of calling the models")
 	logLevelFlag := flag.String("log-level", "info", "The log level to use for troubleshooting (e.g. debug, info, warn, error)")
 	flag.Parse()
 
@@ -48,7 +70,34 @@ func main() {
 		log.Fatalf("ERROR: failed to instantiate 'logger': %v", err)
 	}
 
-	cfg, err := config.New(*modelFlag, ValidationsFileName, AssistantsFileName)
+	if *recordFlag != "" && *replayFlag != "" {
+		log.Fatal("ERROR: the 'record' and 'replay' flags cannot be used together")
+	}
+
+	if *recordFlag != "" {
+		recorder, err := cassette.NewRecorder(*recordFlag, http.DefaultTransport)
+		if err != nil {
+			log.Fatalf("ERROR: failed to instantiate the cassette recorder: %v", err)
+		}
+		http.DefaultClient.Transport = recorder
+	}
+
+	if *replayFlag != "" {
+		replayer, err := cassette.NewReplayer(*replayFlag)
+		if err != nil {
+			log.Fatalf("ERROR: failed to instantiate the cassette replayer: %v", err)
+		}
+		http.DefaultClient.Transport = replayer
+	}
+
+	// No API key is needed when the answers are replayed.
+	isReplay := *replayFlag != ""
+	requireAPIKey := !isReplay
+	// In compare mode, the prompts are only sent to the compared models, so the model
+	// flag needs no API key.
+	isCompareMode := *compareFlag != ""
+
+	cfg, err := config.New(*modelFlag, requireAPIKey && !isCompareMode, ValidationsFileName, AssistantsFileName)
 	if err != nil {
 		log.Fatalf("ERROR: failed to instantiate 'config': %v", err)
 	}
@@ -63,34 +112,86 @@ func main() {
 		log.Fatalf("ERROR: failed to instantiate 'assistant': %v", err)
 	}
 
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.Open(auditPath(cfg.ConfigDir, cfg.Audit))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
+
 	logger.Info(
 		"Gail started. Loading LLM model...",
 		slog.String("model", string(cfg.Model)),
 		slog.Int("max_token", int(cfg.ModelMaxToken)),
 	)
 
-	var llm tui.LLM
-	switch cfg.Model {
-	case models.ModelGPTName:
-		llm, err = gpt.New(logger, cfg.ModelAPIKey, cfg.Model, cfg.ModelMaxToken, AppName, validator)
-		if err != nil {
-			log.Fatalf("ERROR: failed to instantiate 'ChatGPT' model: %v", err)
+	mockScriptPath := *mockScriptFlag
+	if mockScriptPath == "" {
+		mockScriptPath = filepath.Join(cfg.ConfigDir, MockScriptFileName)
+	}
+
+	var llm models.LLM
+	var compareLLMs []models.LLM
+	// Whether all the models are local, so that the prompts never leave the machine.
+	localOnly := true
+
+	if isCompareMode {
+		for _, modelFlag := range strings.Split(*compareFlag, ",") {
+			modelCfg, err := config.NewModelConfig(strings.TrimSpace(modelFlag), requireAPIKey)
+			if err != nil {
+				log.Fatalf("ERROR: failed to configure the '%s' model to compare: %v", modelFlag, err)
+			}
+
+			compareLLM, err := newLLM(logger, modelCfg, mockScriptPath, isReplay, validator, auditLog)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			compareLLMs = append(compareLLMs, compareLLM)
+			localOnly = localOnly && modelCfg.Model == models.ModelMockName
 		}
-	case models.ModelGPToName:
-		llm, err = gpto.New(logger, cfg.ModelAPIKey, cfg.Model, cfg.ModelMaxToken, AppName, validator)
+
+		// The first compared model stands for the selected one until the user picks the best one.
+		llm = compareLLMs[0]
+	} else {
+		llm, err = newLLM(logger, config.ModelConfig{Model: cfg.Model, MaxTokens: cfg.ModelMaxToken, APIKey: cfg.ModelAPIKey}, mockScriptPath, isReplay, validator, auditLog)
 		if err != nil {
-			log.Fatalf("ERROR: failed to instantiate 'ChatGPT-o' model: %v", err)
+			log.Fatalf("ERROR: %v", err)
 		}
-	case models.ModelClaudeName:
-		llm, err = claude.New(logger, cfg.ModelAPIKey, cfg.Model, cfg.ModelMaxToken, AppName, validator)
+		localOnly = cfg.Model == models.ModelMockName
+	}
+
+	if len(cfg.Fallback) > 0 && !isCompareMode {
+		chain := []models.LLM{llm}
+		for _, fallbackFlag := range cfg.Fallback {
+			if fallbackFlag == *modelFlag {
+				continue
+			}
+
+			modelCfg, err := config.NewModelConfig(fallbackFlag, requireAPIKey)
+			if err != nil {
+				log.Fatalf("ERROR: failed to configure the '%s' fallback model: %v", fallbackFlag, err)
+			}
+
+			fallbackLLM, err := newLLM(logger, modelCfg, mockScriptPath, isReplay, validator, auditLog)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			chain = append(chain, fallbackLLM)
+			localOnly = localOnly && modelCfg.Model == models.ModelMockName
+		}
+
+		llm, err = fallback.New(logger, chain)
 		if err != nil {
-			log.Fatalf("ERROR: failed to instantiate 'Claude' model: %v", err)
+			log.Fatalf("ERROR: failed to instantiate the fallback chain: %v", err)
 		}
-	default:
-		log.Fatalf("ERROR: failed to instantiate a model. '%s' is not supported", cfg.Model)
 	}
 
-	tui := tui.New(logger, llm, assistant)
+	if localOnly {
+		validator.SetLocalOnly()
+	}
+
+	tui := tui.New(logger, llm, compareLLMs, assistant, validator, cfg.AutoContinue)
 	if err != nil {
 		log.Fatalf("ERROR: failed to instantiate the Terminal User Interface: %v", err)
 	}
@@ -100,3 +201,55 @@ func main() {
 		log.Fatalf("ERROR: failed to run the Terminal User Interface: %v", err)
 	}
 }
+
+// newLLM instantiates the LLM backend matching the given model configuration, behind the
+// pipeline validating, redacting and auditing the requests sent to it. When the responses are
+// replayed, the recorded OpenAI Runs are polled without waiting.
+func newLLM(logger *slog.Logger, modelCfg config.ModelConfig, mockScriptPath string, isReplay bool, validator *validator.Validator, auditLog *audit.Log) (models.LLM, error) {
+	var llm models.LLM
+	var provider string
+	switch modelCfg.Model {
+	case models.ModelGPTName:
+		gptLLM, err := gpt.New(logger, modelCfg.APIKey, modelCfg.Model, modelCfg.MaxTokens, AppName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate 'ChatGPT' model: %w", err)
+		}
+		if isReplay {
+			gptLLM.PollInterval = 0
+		}
+		llm, provider = gptLLM, models.ProviderOpenAI
+	case models.ModelGPToName:
+		gptoLLM, err := gpto.New(logger, modelCfg.APIKey, modelCfg.Model, modelCfg.MaxTokens, AppName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate 'ChatGPT-o' model: %w", err)
+		}
+		llm, provider = gptoLLM, models.ProviderOpenAI
+	case models.ModelClaudeName:
+		claudeLLM, err := claude.New(logger, modelCfg.APIKey, modelCfg.Model, modelCfg.MaxTokens, AppName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate 'Claude' model: %w", err)
+		}
+		llm, provider = claudeLLM, models.ProviderAnthropic
+	case models.ModelMockName:
+		mockLLM, err := mock.New(logger, mockScriptPath, AppName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate the 'mock' model: %w", err)
+		}
+		llm, provider = mockLLM, mock.ProviderMock
+	default:
+		return nil, fmt.Errorf("failed to instantiate a model. '%s' is not supported", modelCfg.Model)
+	}
+
+	return pipeline.New(llm,
+		pipeline.Validate(validator),
+		pipeline.Audit(auditLog, provider),
+	), nil
+}
+
+// auditPath returns the path of the audit log file, in the configuration directory unless set otherwise.
+func auditPath(configDir string, auditCfg config.AuditConfig) string {
+	if auditCfg.Path != "" {
+		return auditCfg.Path
+	}
+	return filepath.Join(configDir, audit.FileName)
+}